@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ==================== 客户端中间件：超时、重试、对冲 ====================
+// 服务端拦截器包住的是 handler，客户端拦截器包住的是“发起一次调用”。
+// 同一套代码既可以包 MiniServer.Call，也可以转成 grpc.UnaryClientInterceptor：
+//
+//	call := WrapInvoker(server.Call, Timeout(timeouts), Retry(policy))
+//	conn, _ := grpc.NewClient(addr, grpc.WithChainUnaryInterceptor(
+//		ToUnaryClientInterceptors(Timeout(timeouts), Retry(policy))...))
+//
+// 顺序有讲究：Timeout 在 Retry 外面是“所有重试加起来”的总超时，放在里面是每次尝试的超时。
+
+// Invoker 发起一次调用，签名和 MiniServer.Call 一样
+type Invoker func(ctx context.Context, method string, req interface{}) (interface{}, error)
+
+// ClientInterceptor 客户端拦截器
+type ClientInterceptor func(ctx context.Context, method string, req interface{}, invoker Invoker) (interface{}, error)
+
+// WrapInvoker 把客户端拦截器链套在 invoker 外面，第一个拦截器在最外层
+func WrapInvoker(invoker Invoker, interceptors ...ClientInterceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+			return interceptor(ctx, method, req, next)
+		}
+	}
+	return invoker
+}
+
+// ToUnaryClientInterceptor 转成 gRPC 客户端拦截器
+// 每次尝试都用一个新的 reply，成功的那次再合并回调用方传入的 reply，
+// 对冲请求并发执行时不会同时写同一个对象
+func ToUnaryClientInterceptor(interceptor ClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		msg, ok := reply.(proto.Message)
+		if !ok {
+			return status.Errorf(codes.Internal, "reply 不是 proto.Message: %T", reply)
+		}
+		resp, err := interceptor(ctx, method, req, func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+			attempt := msg.ProtoReflect().New().Interface()
+			if err := invoker(ctx, method, req, attempt, cc, opts...); err != nil {
+				return nil, err
+			}
+			return attempt, nil
+		})
+		if err != nil {
+			return err
+		}
+		proto.Reset(msg)
+		proto.Merge(msg, resp.(proto.Message))
+		return nil
+	}
+}
+
+// ToUnaryClientInterceptors 批量转换，方便传给 grpc.WithChainUnaryInterceptor
+func ToUnaryClientInterceptors(interceptors ...ClientInterceptor) []grpc.UnaryClientInterceptor {
+	out := make([]grpc.UnaryClientInterceptor, len(interceptors))
+	for i, interceptor := range interceptors {
+		out[i] = ToUnaryClientInterceptor(interceptor)
+	}
+	return out
+}
+
+// MethodMatcher 按方法名匹配，支持 path.Match 通配符，如 /proto.Greeter/Get*
+func MethodMatcher(patterns ...string) func(method string) bool {
+	return func(method string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, method); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ==================== 超时 ====================
+
+// TimeoutPolicy 每个方法的超时时间
+type TimeoutPolicy struct {
+	Default time.Duration            // 未单独配置的方法，0 表示不设置
+	Methods map[string]time.Duration // key 支持通配符
+}
+
+func (p TimeoutPolicy) timeoutFor(method string) time.Duration {
+	if d, ok := p.Methods[method]; ok {
+		return d
+	}
+	for pattern, d := range p.Methods {
+		if ok, _ := path.Match(pattern, method); ok {
+			return d
+		}
+	}
+	return p.Default
+}
+
+// Timeout 给没有 deadline 的调用加上超时；调用方已经设置了更短的 deadline 时以调用方为准
+func Timeout(policy TimeoutPolicy) ClientInterceptor {
+	return func(ctx context.Context, method string, req interface{}, invoker Invoker) (interface{}, error) {
+		if d := policy.timeoutFor(method); d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		return invoker(ctx, method, req)
+	}
+}
+
+// ==================== 重试 ====================
+
+// RetryPolicy 重试策略，字段含义和 gRPC service config 里的 retryPolicy 一致
+type RetryPolicy struct {
+	MaxAttempts    int           // 包含第一次调用，默认 3
+	InitialBackoff time.Duration // 默认 100ms
+	MaxBackoff     time.Duration // 默认 5s
+	Multiplier     float64       // 默认 2
+	Jitter         float64       // 随机抖动比例，0.2 表示 ±20%，默认 0.2
+	RetryableCodes []codes.Code  // 默认只重试 Unavailable
+	// Idempotent 判断方法是否幂等，只有幂等方法才会重试；为空时不重试任何方法
+	Idempotent func(method string) bool
+	// Budget 重试预算，多个拦截器可以共享一个，防止故障时重试把下游压垮
+	Budget *RetryBudget
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.Multiplier <= 0 {
+		p.Multiplier = 2
+	}
+	if p.Jitter <= 0 {
+		p.Jitter = 0.2
+	}
+	if len(p.RetryableCodes) == 0 {
+		p.RetryableCodes = []codes.Code{codes.Unavailable}
+	}
+	return p
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第 n 次重试前的等待时间：指数增长、封顶，再加上随机抖动
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	d = math.Min(d, float64(p.MaxBackoff))
+	d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// Retry 对幂等方法的可重试错误做指数退避重试
+func Retry(policy RetryPolicy) ClientInterceptor {
+	policy = policy.withDefaults()
+	return func(ctx context.Context, method string, req interface{}, invoker Invoker) (interface{}, error) {
+		if policy.Idempotent == nil || !policy.Idempotent(method) {
+			return invoker(ctx, method, req)
+		}
+
+		for attempt := 1; ; attempt++ {
+			resp, err := invoker(ctx, method, req)
+			policy.Budget.record(err == nil || !policy.retryable(err))
+			if err == nil || !policy.retryable(err) || attempt >= policy.MaxAttempts || !policy.Budget.allow() {
+				return resp, err
+			}
+			if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// sleep 等待一段时间，ctx 先结束时提前返回
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// RetryBudget 重试预算（gRPC 的 retryThrottling 算法）：
+// 每次失败扣 1 个令牌，每次成功加 tokenRatio 个，令牌不超过一半时停止重试。
+// 下游持续故障时重试会自动停下来，恢复后又自动放开。
+type RetryBudget struct {
+	mu         sync.Mutex
+	maxTokens  float64
+	tokenRatio float64
+	tokens     float64
+}
+
+func NewRetryBudget(maxTokens, tokenRatio float64) *RetryBudget {
+	return &RetryBudget{maxTokens: maxTokens, tokenRatio: tokenRatio, tokens: maxTokens}
+}
+
+func (b *RetryBudget) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.tokens = math.Min(b.tokens+b.tokenRatio, b.maxTokens)
+	} else {
+		b.tokens = math.Max(b.tokens-1, 0)
+	}
+}
+
+func (b *RetryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
+
+// ==================== 对冲请求 ====================
+
+// HedgePolicy 对冲策略：第一个请求迟迟没有响应时，再发一个，谁先成功用谁
+type HedgePolicy struct {
+	MaxAttempts int           // 包含第一次，默认 2
+	Delay       time.Duration // 多久没响应就发下一个，默认 50ms
+	// NonFatalCodes 这些错误不会结束对冲，而是立刻发下一个；其它错误直接返回
+	NonFatalCodes []codes.Code
+	// Idempotent 只有幂等方法才能对冲（请求可能被执行多次）
+	Idempotent func(method string) bool
+}
+
+// Hedge 对冲请求，降低长尾延迟；第一个成功（或遇到致命错误）后取消其余请求
+func Hedge(policy HedgePolicy) ClientInterceptor {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 2
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = 50 * time.Millisecond
+	}
+	nonFatal := RetryPolicy{RetryableCodes: policy.NonFatalCodes}.withDefaults()
+
+	return func(ctx context.Context, method string, req interface{}, invoker Invoker) (interface{}, error) {
+		if policy.Idempotent == nil || !policy.Idempotent(method) {
+			return invoker(ctx, method, req)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // 返回时取消还在进行中的请求
+
+		type result struct {
+			resp interface{}
+			err  error
+		}
+		results := make(chan result, policy.MaxAttempts)
+		launch := func() {
+			go func() {
+				resp, err := invoker(ctx, method, req)
+				results <- result{resp, err}
+			}()
+		}
+
+		launch()
+		sent, received := 1, 0
+		timer := time.NewTimer(policy.Delay)
+		defer timer.Stop()
+
+		var lastErr error
+		for {
+			select {
+			case <-timer.C:
+				if sent < policy.MaxAttempts {
+					launch()
+					sent++
+					timer.Reset(policy.Delay)
+				}
+			case r := <-results:
+				received++
+				if r.err == nil || !nonFatal.retryable(r.err) {
+					return r.resp, r.err
+				}
+				lastErr = r.err
+				// 非致命错误：不用等 Delay，立刻补发
+				if sent < policy.MaxAttempts {
+					launch()
+					sent++
+					timer.Reset(policy.Delay)
+				} else if received == sent {
+					return nil, lastErr
+				}
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testMethod = "/test.Service/Get"
+
+// unavailable 永远返回 Unavailable 的 invoker，并统计调用次数
+func unavailable(calls *atomic.Int32) Invoker {
+	return func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+		calls.Add(1)
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+}
+
+func ok(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestRetry_NonIdempotentNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	invoker := WrapInvoker(unavailable(&calls), Retry(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		Idempotent:     MethodMatcher("/test.Service/List"),
+	}))
+
+	if _, err := invoker(context.Background(), testMethod, nil); status.Code(err) != codes.Unavailable {
+		t.Fatalf("错误 = %v，期望 Unavailable", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("非幂等方法调用了 %d 次，期望 1 次", n)
+	}
+}
+
+func TestRetry_StopsAtMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	invoker := WrapInvoker(unavailable(&calls), Retry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Idempotent:     MethodMatcher(testMethod),
+	}))
+
+	if _, err := invoker(context.Background(), testMethod, nil); status.Code(err) != codes.Unavailable {
+		t.Fatalf("错误 = %v，期望 Unavailable", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("调用了 %d 次，期望 3 次", n)
+	}
+}
+
+func TestRetry_BudgetExhausted(t *testing.T) {
+	// 4 个令牌，不超过 2 个时停止重试；每次成功只加 0.1 个
+	budget := NewRetryBudget(4, 0.1)
+	policy := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Millisecond,
+		Idempotent:     MethodMatcher(testMethod),
+		Budget:         budget,
+	}
+	var calls atomic.Int32
+	failing := WrapInvoker(unavailable(&calls), Retry(policy))
+	healthy := WrapInvoker(ok, Retry(policy)) // 两个拦截器共享同一个预算
+
+	// 第一次调用：4 -> 3 还能重试，3 -> 2 预算用完
+	failing(context.Background(), testMethod, nil)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("第一次调用尝试了 %d 次，期望 2 次", n)
+	}
+
+	// 预算用完后不再重试，每次调用只尝试一次
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		failing(context.Background(), testMethod, nil)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("预算用完后 3 次调用尝试了 %d 次，期望 3 次", n)
+	}
+
+	// 令牌已经扣到 0，成功 31 次后回到 3.1 个：失败一次扣到 2.1 个，仍然可以重试
+	for i := 0; i < 31; i++ {
+		if _, err := healthy(context.Background(), testMethod, nil); err != nil {
+			t.Fatalf("healthy: %v", err)
+		}
+	}
+	calls.Store(0)
+	failing(context.Background(), testMethod, nil)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("预算恢复后尝试了 %d 次，期望 2 次", n)
+	}
+}
+
+func TestRetry_StopsWhenContextDone(t *testing.T) {
+	var calls atomic.Int32
+	invoker := WrapInvoker(unavailable(&calls), Retry(RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Hour,
+		Idempotent:     MethodMatcher(testMethod),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := invoker(ctx, testMethod, nil); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("错误 = %v，期望 DeadlineExceeded", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("调用了 %d 次，期望 1 次", n)
+	}
+}
+
+func TestTimeout_AppliesToWholeRetryLoop(t *testing.T) {
+	var calls atomic.Int32
+	// Timeout 在外，Retry 在内：超时是所有重试加起来的总时间
+	invoker := WrapInvoker(unavailable(&calls),
+		Timeout(TimeoutPolicy{Default: 30 * time.Millisecond}),
+		Retry(RetryPolicy{
+			MaxAttempts:    100,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+			Idempotent:     MethodMatcher(testMethod),
+		}),
+	)
+
+	start := time.Now()
+	if _, err := invoker(context.Background(), testMethod, nil); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("错误 = %v，期望 DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("耗时 %v，超时没有生效", elapsed)
+	}
+	if n := calls.Load(); n >= 100 {
+		t.Errorf("调用了 %d 次，超时后应该停止重试", n)
+	}
+}
+
+func TestHedge_CancelsSlowAttempt(t *testing.T) {
+	var calls atomic.Int32
+	slowCanceled := make(chan struct{})
+	invoker := func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			// 第一个请求卡住，直到对冲拦截器取消它
+			<-ctx.Done()
+			close(slowCanceled)
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return "fast", nil
+	}
+	hedged := WrapInvoker(invoker, Hedge(HedgePolicy{
+		MaxAttempts: 2,
+		Delay:       10 * time.Millisecond,
+		Idempotent:  MethodMatcher(testMethod),
+	}))
+
+	resp, err := hedged(context.Background(), testMethod, nil)
+	if err != nil || resp != "fast" {
+		t.Fatalf("hedged = %v, %v，期望 fast", resp, err)
+	}
+	select {
+	case <-slowCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("第二个请求成功后，第一个请求没有被取消")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("发出了 %d 个请求，期望 2 个", n)
+	}
+}
+
+func TestHedge_NonFatalErrorLaunchesImmediately(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			return nil, status.Error(codes.Unavailable, "down")
+		}
+		return "second", nil
+	}
+	hedged := WrapInvoker(invoker, Hedge(HedgePolicy{
+		MaxAttempts: 2,
+		Delay:       time.Hour, // 不等 Delay，非致命错误后立刻补发
+		Idempotent:  MethodMatcher(testMethod),
+	}))
+
+	resp, err := hedged(context.Background(), testMethod, nil)
+	if err != nil || resp != "second" {
+		t.Fatalf("hedged = %v, %v，期望 second", resp, err)
+	}
+}
+
+func TestHedge_FatalErrorReturned(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req interface{}) (interface{}, error) {
+		calls.Add(1)
+		return nil, status.Error(codes.InvalidArgument, "bad")
+	}
+	hedged := WrapInvoker(invoker, Hedge(HedgePolicy{
+		MaxAttempts: 3,
+		Delay:       time.Hour,
+		Idempotent:  MethodMatcher(testMethod),
+	}))
+
+	if _, err := hedged(context.Background(), testMethod, nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("错误 = %v，期望 InvalidArgument", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("致命错误后又发了请求，共 %d 个", n)
+	}
+}
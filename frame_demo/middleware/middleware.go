@@ -0,0 +1,85 @@
+// Package middleware 是 mini-framework 和 serverx-simplified 共用的中间件。
+//
+// mini-framework 的 Interceptor 和 gRPC 的 UnaryServerInterceptor 本质上是同一个东西，
+// 区别只是 gRPC 多了一个 *grpc.UnaryServerInfo 参数（里面只有 FullMethod 和服务实现）。
+// 这里把方法名放进 context，两种形式就可以无损地互相转换：
+//
+//	ToUnaryServerInterceptor(Recovery())             // 写一次，ServerX 直接用
+//	FromUnaryServerInterceptor(jwtInterceptor)       // 现成的 gRPC 拦截器，MiniServer 直接用
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// Handler 业务处理函数，和 grpc.UnaryHandler 的签名完全一样
+type Handler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// Interceptor 拦截器，相当于去掉了 UnaryServerInfo 的 grpc.UnaryServerInterceptor
+// 需要方法名时用 MethodFromContext(ctx)
+type Interceptor func(ctx context.Context, req interface{}, handler Handler) (interface{}, error)
+
+type methodKey struct{}
+
+// WithMethod 把完整方法名（/package.Service/Method）放进 context
+func WithMethod(ctx context.Context, fullMethod string) context.Context {
+	return context.WithValue(ctx, methodKey{}, fullMethod)
+}
+
+// MethodFromContext 取出完整方法名
+// gRPC 服务端上也能用：grpc.Method(ctx) 作为兜底
+func MethodFromContext(ctx context.Context) string {
+	if method, ok := ctx.Value(methodKey{}).(string); ok {
+		return method
+	}
+	if method, ok := grpc.Method(ctx); ok {
+		return method
+	}
+	return ""
+}
+
+// FullMethod 把服务名和方法名拼成 gRPC 的完整方法名
+// method 本身已经是 /xxx/yyy 形式时原样返回
+func FullMethod(service, method string) string {
+	if strings.HasPrefix(method, "/") {
+		return method
+	}
+	return "/" + service + "/" + method
+}
+
+// SplitMethod 把 /package.Service/Method 拆成服务名和方法名
+func SplitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// ToUnaryServerInterceptor 把 Interceptor 转成 gRPC 拦截器
+func ToUnaryServerInterceptor(interceptor Interceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptor(WithMethod(ctx, info.FullMethod), req, Handler(handler))
+	}
+}
+
+// FromUnaryServerInterceptor 把 gRPC 拦截器转成 Interceptor
+// FullMethod 来自 context（MiniServer.Call 会放进去），Server 字段为 nil
+func FromUnaryServerInterceptor(interceptor grpc.UnaryServerInterceptor) Interceptor {
+	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		info := &grpc.UnaryServerInfo{FullMethod: MethodFromContext(ctx)}
+		return interceptor(ctx, req, info, grpc.UnaryHandler(handler))
+	}
+}
+
+// ToUnaryServerInterceptors 批量转换，方便直接传给 grpc.ChainUnaryInterceptor
+func ToUnaryServerInterceptors(interceptors ...Interceptor) []grpc.UnaryServerInterceptor {
+	out := make([]grpc.UnaryServerInterceptor, len(interceptors))
+	for i, interceptor := range interceptors {
+		out[i] = ToUnaryServerInterceptor(interceptor)
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeTransportStream 让 grpc.Method(ctx) 在测试里也能取到方法名（真实服务端由 grpc 放进 context）
+type fakeTransportStream struct{ method string }
+
+func (s fakeTransportStream) Method() string               { return s.method }
+func (s fakeTransportStream) SetHeader(metadata.MD) error  { return nil }
+func (s fakeTransportStream) SendHeader(metadata.MD) error { return nil }
+func (s fakeTransportStream) SetTrailer(metadata.MD) error { return nil }
+
+func TestMethodFromContext(t *testing.T) {
+	if got := MethodFromContext(context.Background()); got != "" {
+		t.Errorf("空 context 返回 %q，期望空字符串", got)
+	}
+
+	ctx := WithMethod(context.Background(), "/mini.MiniServer/SayHello")
+	if got := MethodFromContext(ctx); got != "/mini.MiniServer/SayHello" {
+		t.Errorf("MethodFromContext = %q", got)
+	}
+
+	// gRPC 服务端上没有 WithMethod 时，兜底用 grpc.Method(ctx)
+	grpcCtx := grpc.NewContextWithServerTransportStream(context.Background(), fakeTransportStream{"/proto.Greeter/SayHello"})
+	if got := MethodFromContext(grpcCtx); got != "/proto.Greeter/SayHello" {
+		t.Errorf("grpc 兜底 = %q", got)
+	}
+	// 两者都有时 WithMethod 优先
+	if got := MethodFromContext(WithMethod(grpcCtx, "/a.B/C")); got != "/a.B/C" {
+		t.Errorf("WithMethod 应该优先，得到 %q", got)
+	}
+}
+
+func TestFullMethodAndSplitMethod(t *testing.T) {
+	tests := []struct {
+		service, method, full string
+	}{
+		{"mini.MiniServer", "SayHello", "/mini.MiniServer/SayHello"},
+		{"mini.MiniServer", "admin.CreateUser", "/mini.MiniServer/admin.CreateUser"},
+		{"mini.MiniServer", "/proto.Greeter/SayHello", "/proto.Greeter/SayHello"}, // 已经是完整方法名
+	}
+	for _, tt := range tests {
+		if got := FullMethod(tt.service, tt.method); got != tt.full {
+			t.Errorf("FullMethod(%q, %q) = %q，期望 %q", tt.service, tt.method, got, tt.full)
+		}
+	}
+
+	if service, method := SplitMethod("/proto.Greeter/SayHello"); service != "proto.Greeter" || method != "SayHello" {
+		t.Errorf("SplitMethod = %q, %q", service, method)
+	}
+	if service, method := SplitMethod("SayHello"); service != "" || method != "SayHello" {
+		t.Errorf("SplitMethod(没有服务名) = %q, %q", service, method)
+	}
+}
+
+func TestToUnaryServerInterceptor(t *testing.T) {
+	var seen string
+	interceptor := ToUnaryServerInterceptor(func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		seen = MethodFromContext(ctx)
+		resp, err := handler(ctx, req)
+		return resp.(string) + "!", err
+	})
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.Greeter/SayHello"}
+	resp, err := interceptor(context.Background(), "hi", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+	if err != nil || resp != "hi!" {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	if seen != info.FullMethod {
+		t.Errorf("拦截器看到的方法名 = %q，期望 %q", seen, info.FullMethod)
+	}
+}
+
+func TestFromUnaryServerInterceptor(t *testing.T) {
+	wantErr := errors.New("拒绝")
+	var info *grpc.UnaryServerInfo
+	interceptor := FromUnaryServerInterceptor(func(ctx context.Context, req interface{}, i *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		info = i
+		if req == "bad" {
+			return nil, wantErr
+		}
+		return handler(ctx, req)
+	})
+	echo := func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil }
+	ctx := WithMethod(context.Background(), "/mini.MiniServer/SayHello")
+
+	if resp, err := interceptor(ctx, "hi", echo); err != nil || resp != "hi" {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	if info.FullMethod != "/mini.MiniServer/SayHello" || info.Server != nil {
+		t.Errorf("UnaryServerInfo = %+v，期望 FullMethod 来自 context、Server 为 nil", info)
+	}
+	if _, err := interceptor(ctx, "bad", echo); !errors.Is(err, wantErr) {
+		t.Errorf("错误 = %v，期望原样返回 %v", err, wantErr)
+	}
+}
+
+func TestServerInterceptorRoundTrip(t *testing.T) {
+	// Interceptor -> gRPC -> Interceptor 转两次，行为和方法名都不变
+	var order []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+			order = append(order, name+":"+MethodFromContext(ctx))
+			return handler(ctx, req)
+		}
+	}
+	grpcInterceptors := ToUnaryServerInterceptors(record("a"), record("b"))
+	if len(grpcInterceptors) != 2 {
+		t.Fatalf("转换后有 %d 个拦截器，期望 2 个", len(grpcInterceptors))
+	}
+
+	var handler Handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return req, nil
+	}
+	for i := len(grpcInterceptors) - 1; i >= 0; i-- {
+		interceptor, next := FromUnaryServerInterceptor(grpcInterceptors[i]), handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+
+	resp, err := handler(WithMethod(context.Background(), "/a.B/C"), "req")
+	if err != nil || resp != "req" {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	want := []string{"a:/a.B/C", "b:/a.B/C", "handler"}
+	if len(order) != len(want) {
+		t.Fatalf("执行顺序 = %v，期望 %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("执行顺序 = %v，期望 %v", order, want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 拦截器链追踪 & Explain ====================
+// 请求被拒绝时，最想知道的是“链里哪个拦截器提前返回了”。
+// Traced 把每个拦截器包一层，记录进入/退出时间、是否调用了下一个 handler、返回的状态码，
+// 所有记录按进入顺序挂在 context 里的 Trace 上：
+//
+//	/mini.MiniServer/admin.CreateUser  42µs  PermissionDenied
+//	  ✅ Logging                        +0s    40µs  -> next
+//	    ✅ AuthInterceptor              +2µs   38µs  -> next
+//	      ✅ (*RateLimiter).Interceptor +10µs  30µs  -> next
+//	        ⛔ AdminAuthInterceptor     +11µs  8µs   提前返回 PermissionDenied: 需要管理员权限
+//
+// 追踪是可选的：只有被 Traced 包过、且 context 里有 Trace 时才会记录。
+
+// Span 一个拦截器（或最终的业务 handler）的执行记录
+type Span struct {
+	Name       string        `json:"name"`
+	Depth      int           `json:"depth"`       // 在链中的嵌套层级，0 是最外层
+	Offset     time.Duration `json:"offset_ns"`   // 相对请求开始的时间
+	Duration   time.Duration `json:"duration_ns"` // 包含它内部所有拦截器的耗时
+	CalledNext bool          `json:"called_next"` // false 表示在这里短路（提前返回）
+	Code       string        `json:"code"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Trace 一次请求的完整时间线
+type Trace struct {
+	Method   string        `json:"method"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration_ns"`
+	Code     string        `json:"code"`
+	Error    string        `json:"error,omitempty"`
+	Spans    []*Span       `json:"spans"`
+
+	mu    sync.Mutex
+	depth int
+}
+
+type traceKey struct{}
+
+// NewTrace 开始记录一次请求
+func NewTrace(method string) *Trace {
+	return &Trace{Method: method, Start: time.Now()}
+}
+
+// ContextWithTrace 把 Trace 挂到 context 上，之后被 Traced 包过的拦截器都会往里记录
+func ContextWithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext 取出当前请求的 Trace，没有开启追踪时返回 nil
+func TraceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// Finish 记录整个请求的结果
+func (t *Trace) Finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Duration = time.Since(t.Start)
+	t.Code = status.Code(err).String()
+	if err != nil {
+		t.Error = status.Convert(err).Message()
+	}
+}
+
+// enter 记录进入，返回的函数在退出时调用
+func (t *Trace) enter(name string) (calledNext func(), exit func(err error)) {
+	t.mu.Lock()
+	span := &Span{Name: name, Depth: t.depth, Offset: time.Since(t.Start)}
+	t.Spans = append(t.Spans, span)
+	t.depth++
+	t.mu.Unlock()
+
+	start := time.Now()
+	calledNext = func() {
+		t.mu.Lock()
+		span.CalledNext = true
+		t.mu.Unlock()
+	}
+	exit = func(err error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.depth--
+		span.Duration = time.Since(start)
+		span.Code = status.Code(err).String()
+		if err != nil {
+			span.Error = status.Convert(err).Message()
+		}
+	}
+	return calledNext, exit
+}
+
+// Explain 人类可读的时间线
+func (t *Trace) Explain() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %v  %s\n", t.Method, t.Duration, t.Code)
+	for _, span := range t.Spans {
+		mark, outcome := "✅", "-> next"
+		result := span.Code
+		if span.Error != "" {
+			result += ": " + span.Error
+		}
+		switch {
+		case span.Name == handlerSpanName:
+			outcome = "业务处理 " + result
+			if span.Code != "OK" {
+				mark = "❌"
+			}
+		case !span.CalledNext:
+			outcome = "提前返回 " + result
+			if span.Code != "OK" {
+				mark = "⛔"
+			}
+		}
+		fmt.Fprintf(&b, "%s%s %-*s +%-12v %-12v %s\n",
+			strings.Repeat("  ", span.Depth+1), mark, 28-2*span.Depth, span.Name, span.Offset, span.Duration, outcome)
+	}
+	return b.String()
+}
+
+// handlerSpanName 链的最后一环（真正的业务处理函数）
+const handlerSpanName = "handler"
+
+// Traced 包装一个拦截器，记录它的执行情况
+func Traced(name string, interceptor Interceptor) Interceptor {
+	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		trace := TraceFromContext(ctx)
+		if trace == nil {
+			return interceptor(ctx, req, handler)
+		}
+		calledNext, exit := trace.enter(name)
+		resp, err := interceptor(ctx, req, func(ctx context.Context, req interface{}) (interface{}, error) {
+			calledNext()
+			return handler(ctx, req)
+		})
+		exit(err)
+		return resp, err
+	}
+}
+
+// Instrument 给整条链加上追踪：每个拦截器用函数名命名，最后追加一个记录业务 handler 的拦截器
+// 可以直接喂给 Chain：Chain(Instrument(interceptors...)...)
+func Instrument(interceptors ...Interceptor) []Interceptor {
+	out := make([]Interceptor, 0, len(interceptors)+1)
+	for _, interceptor := range interceptors {
+		out = append(out, Traced(FuncName(interceptor), interceptor))
+	}
+	return append(out, Traced(handlerSpanName, func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		return handler(ctx, req)
+	}))
+}
+
+// FuncName 取函数名作为拦截器的显示名，main.AuthInterceptor.func1 -> AuthInterceptor
+func FuncName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	for {
+		i := strings.LastIndex(name, ".func")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// ==================== gRPC 拦截器链的追踪 ====================
+
+// TracedUnary 和 Traced 一样，直接包装 gRPC 拦截器（保留 UnaryServerInfo 原样传递）
+func TracedUnary(name string, interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		trace := TraceFromContext(ctx)
+		if trace == nil {
+			return interceptor(ctx, req, info, handler)
+		}
+		calledNext, exit := trace.enter(name)
+		resp, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			calledNext()
+			return handler(ctx, req)
+		})
+		exit(err)
+		return resp, err
+	}
+}
+
+// Tracer 为每个 gRPC 请求创建 Trace，并保留最近 limit 条供调试页面查看
+type Tracer struct {
+	mu     sync.Mutex
+	limit  int
+	recent []*Trace // 环形缓冲，next 指向下一个写入位置
+	next   int
+}
+
+func NewTracer(limit int) *Tracer {
+	if limit <= 0 {
+		limit = 100
+	}
+	return &Tracer{limit: limit}
+}
+
+// InstrumentUnary 返回带追踪的 gRPC 拦截器链：
+// 最外层负责创建/保存 Trace，中间是被 TracedUnary 包过的原拦截器，最内层记录业务 handler
+// names 可以为某些下标指定显示名（适配器包过的拦截器从函数名看不出来源）
+func (tr *Tracer) InstrumentUnary(interceptors []grpc.UnaryServerInterceptor, names map[int]string) []grpc.UnaryServerInterceptor {
+	out := make([]grpc.UnaryServerInterceptor, 0, len(interceptors)+2)
+	out = append(out, tr.root)
+	for i, interceptor := range interceptors {
+		name, ok := names[i]
+		if !ok {
+			name = FuncName(interceptor)
+		}
+		out = append(out, TracedUnary(name, interceptor))
+	}
+	return append(out, TracedUnary(handlerSpanName, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	}))
+}
+
+func (tr *Tracer) root(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	trace := NewTrace(info.FullMethod)
+	resp, err := handler(ContextWithTrace(ctx, trace), req)
+	trace.Finish(err)
+	tr.record(trace)
+	return resp, err
+}
+
+func (tr *Tracer) record(trace *Trace) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.recent) < tr.limit {
+		tr.recent = append(tr.recent, trace)
+	} else {
+		tr.recent[tr.next] = trace
+	}
+	tr.next = (tr.next + 1) % tr.limit
+}
+
+// Recent 最近的请求，最新的在前
+func (tr *Tracer) Recent() []*Trace {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	out := make([]*Trace, 0, len(tr.recent))
+	for i := 1; i <= len(tr.recent); i++ {
+		out = append(out, tr.recent[(tr.next-i+len(tr.recent))%len(tr.recent)])
+	}
+	return out
+}
+
+// ServeHTTP 调试接口：默认返回 JSON；?format=text 返回 Explain 文本；?method= 按方法名过滤
+func (tr *Tracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Query().Get("method")
+	traces := make([]*Trace, 0)
+	for _, trace := range tr.Recent() {
+		if method == "" || strings.Contains(trace.Method, method) {
+			traces = append(traces, trace)
+		}
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, trace := range traces {
+			fmt.Fprintln(w, trace.Explain())
+		}
+		return
+	}
+
+	// 保存下来的 Trace 都已经 Finish，不会再被修改，可以直接编码
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traces)
+}
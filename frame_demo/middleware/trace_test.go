@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func allow(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+	return handler(ctx, req)
+}
+
+func deny(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+	return nil, status.Error(codes.PermissionDenied, "需要管理员权限")
+}
+
+// runChain 按顺序执行拦截器链（和 mini-framework 的 compile 一样）
+func runChain(ctx context.Context, interceptors []Interceptor, handler Handler) (interface{}, error) {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler(ctx, "req")
+}
+
+// spanSummary 只保留和时间无关的字段，方便比较
+func spanSummary(trace *Trace) []string {
+	var out []string
+	for _, span := range trace.Spans {
+		out = append(out, fmt.Sprintf("%d %s next=%v %s", span.Depth, span.Name, span.CalledNext, span.Code))
+	}
+	return out
+}
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name         string
+		interceptors []Interceptor
+		wantSpans    []string
+		wantCode     codes.Code
+		wantMark     string
+	}{
+		{
+			name:         "全部放行",
+			interceptors: []Interceptor{allow, allow},
+			wantSpans: []string{
+				"0 allow next=true OK",
+				"1 allow next=true OK",
+				"2 handler next=true OK",
+			},
+			wantCode: codes.OK,
+			wantMark: "✅ handler",
+		},
+		{
+			name:         "中间的拦截器提前返回",
+			interceptors: []Interceptor{allow, deny, allow},
+			wantSpans: []string{
+				"0 allow next=true PermissionDenied",
+				"1 deny next=false PermissionDenied",
+			},
+			wantCode: codes.PermissionDenied,
+			wantMark: "⛔ deny",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := NewTrace("/a.B/C")
+			ctx := ContextWithTrace(context.Background(), trace)
+			_, err := runChain(ctx, Instrument(tt.interceptors...), func(ctx context.Context, req interface{}) (interface{}, error) {
+				return req, nil
+			})
+			trace.Finish(err)
+
+			if got := spanSummary(trace); !reflect.DeepEqual(got, tt.wantSpans) {
+				t.Errorf("spans = %q\n期望 %q", got, tt.wantSpans)
+			}
+			if trace.Code != tt.wantCode.String() {
+				t.Errorf("Code = %s，期望 %s", trace.Code, tt.wantCode)
+			}
+			if explain := trace.Explain(); !strings.Contains(explain, tt.wantMark) {
+				t.Errorf("Explain 缺少 %q:\n%s", tt.wantMark, explain)
+			}
+		})
+	}
+}
+
+func TestTraced_WithoutTrace(t *testing.T) {
+	// context 里没有 Trace 时原样执行，不记录
+	resp, err := runChain(context.Background(), Instrument(allow), func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+	if err != nil || resp != "req" {
+		t.Errorf("resp = %v, err = %v", resp, err)
+	}
+}
+
+func TestInstrumentUnary(t *testing.T) {
+	tr := NewTracer(10)
+	grpcInterceptors := tr.InstrumentUnary(ToUnaryServerInterceptors(allow, deny), map[int]string{1: "AdminOnly"})
+
+	interceptors := make([]Interceptor, len(grpcInterceptors))
+	for i, interceptor := range grpcInterceptors {
+		interceptors[i] = FromUnaryServerInterceptor(interceptor)
+	}
+	ctx := WithMethod(context.Background(), "/admin.Service/CreateUser")
+	if _, err := runChain(ctx, interceptors, func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil }); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("错误 = %v，期望 PermissionDenied", err)
+	}
+
+	recent := tr.Recent()
+	if len(recent) != 1 {
+		t.Fatalf("Recent 有 %d 条，期望 1 条", len(recent))
+	}
+	want := []string{
+		"0 ToUnaryServerInterceptor next=true PermissionDenied", // 适配器包过，只能看到函数名
+		"1 AdminOnly next=false PermissionDenied",               // names 指定的显示名
+	}
+	if got := spanSummary(recent[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("spans = %q\n期望 %q", got, want)
+	}
+	if recent[0].Method != "/admin.Service/CreateUser" || recent[0].Error != "需要管理员权限" {
+		t.Errorf("Trace = %s %q", recent[0].Method, recent[0].Error)
+	}
+}
+
+func TestTracer_RecentWrapsAround(t *testing.T) {
+	tr := NewTracer(3)
+	for i := 0; i < 5; i++ {
+		tr.record(&Trace{Method: fmt.Sprintf("m%d", i)})
+	}
+	var got []string
+	for _, trace := range tr.Recent() {
+		got = append(got, trace.Method)
+	}
+	if want := []string{"m4", "m3", "m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Recent = %v，期望 %v（最新的在前，只保留 3 条）", got, want)
+	}
+}
+
+func TestTracer_ServeHTTP(t *testing.T) {
+	tr := NewTracer(10)
+	for _, method := range []string{"/proto.Greeter/SayHello", "/admin.Service/CreateUser"} {
+		trace := NewTrace(method)
+		trace.Finish(nil)
+		tr.record(trace)
+	}
+
+	tests := []struct {
+		query       string
+		contentType string
+		want        []string
+	}{
+		{"", "application/json", []string{"/admin.Service/CreateUser", "/proto.Greeter/SayHello"}},
+		{"?method=Greeter", "application/json", []string{"/proto.Greeter/SayHello"}},
+		{"?method=nothing", "application/json", nil},
+		{"?format=text&method=admin", "text/plain; charset=utf-8", []string{"/admin.Service/CreateUser"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tr.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/traces"+tt.query, nil))
+		if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("%s: Content-Type = %q，期望 %q", tt.query, ct, tt.contentType)
+		}
+
+		var got []string
+		if strings.HasPrefix(tt.contentType, "text/plain") {
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				if strings.HasPrefix(line, "/") {
+					got = append(got, strings.Fields(line)[0])
+				}
+			}
+		} else {
+			var traces []*Trace
+			if err := json.Unmarshal(rec.Body.Bytes(), &traces); err != nil {
+				t.Fatalf("%s: %v", tt.query, err)
+			}
+			for _, trace := range traces {
+				got = append(got, trace.Method)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 方法 = %v，期望 %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFuncName(t *testing.T) {
+	tr := NewTracer(1)
+	tests := []struct {
+		fn   interface{}
+		want string
+	}{
+		{allow, "allow"},
+		{Logging(), "Logging"},
+		{NewRateLimiter(1, 1).Interceptor(), "(*RateLimiter).Interceptor"},
+		{tr.ServeHTTP, "(*Tracer).ServeHTTP"}, // 方法值的 -fm 后缀
+		{func() {}, "TestFuncName"},           // 匿名函数取外层函数名
+		{grpc.UnaryServerInterceptor(nil), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := FuncName(tt.fn); got != tt.want {
+			t.Errorf("FuncName = %q，期望 %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 异步分发：工作池 + 有界队列 ====================
+// Call 在调用方的 goroutine 里同步执行。耗时的任务（发邮件、生成报表）更适合丢进队列：
+//
+//	pool, err := NewWorkerPool(server, 4, 100) // 4 个 worker，最多排队 100 个
+//	future, err := pool.Submit(ctx, "SendEmail", req)
+//	resp, err := future.Wait(ctx)
+//
+// 过载保护分两层，超出时都立即返回 codes.ResourceExhausted，而不是无限堆积：
+//   - 队列深度：整个工作池最多排队 queueSize 个任务
+//   - 单个方法的并发上限：WithMaxConcurrency(n)，排队中和执行中的都算
+// 排队中的任务在 ctx 取消时会直接从队列里移除，不会再占用 worker。
+
+// WithMaxConcurrency - 限制单个方法同时在处理（含排队）的请求数
+// 同步的 Call 同样受限，防止一个慢接口拖垮整个服务
+// n <= 0 会让这个方法永远被拒绝（或者被误当成不限制），和非法的路由模式一样属于注册阶段的编程错误，直接 panic
+func WithMaxConcurrency(n int) RouteOption {
+	if n <= 0 {
+		panic(fmt.Sprintf("WithMaxConcurrency: 并发上限必须大于 0，收到 %d", n))
+	}
+	return func(r *route) {
+		r.limiter = make(chan struct{}, n)
+	}
+}
+
+// acquire 占用一个并发名额，已满时立即拒绝
+func (r *route) acquire(method string) error {
+	if r.limiter == nil {
+		return nil
+	}
+	select {
+	case r.limiter <- struct{}{}:
+		return nil
+	default:
+		return status.Errorf(codes.ResourceExhausted, "%s 并发已达上限 %d", method, cap(r.limiter))
+	}
+}
+
+func (r *route) release() {
+	if r.limiter != nil {
+		<-r.limiter
+	}
+}
+
+// Future 异步调用的结果
+type Future struct {
+	done chan struct{}
+	resp interface{}
+	err  error
+}
+
+// Done 结果就绪时关闭，可以和其它 channel 一起 select
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result 在 Done 关闭后读取结果
+func (f *Future) Result() (interface{}, error) {
+	<-f.done
+	return f.resp, f.err
+}
+
+// Wait 等待结果；ctx 先结束时返回 ctx 的错误（任务本身不受影响）
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (f *Future) resolve(resp interface{}, err error) {
+	f.resp, f.err = resp, err
+	close(f.done)
+}
+
+// task 队列里的一个任务
+type task struct {
+	ctx     context.Context
+	route   *route
+	method  string
+	req     interface{}
+	future  *Future
+	elem    *list.Element // 在队列中的位置，出队后为 nil
+	stopCtx func() bool   // 取消 context.AfterFunc 注册的回调
+}
+
+// WorkerPool 固定数量的 worker 从有界队列中取任务执行
+type WorkerPool struct {
+	server    *MiniServer
+	queueSize int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  *list.List // 用链表而不是 channel，这样取消时可以把任务从中间删掉
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewWorkerPool 创建并启动工作池
+// workers 为 0 时提交的任务永远没人执行，queueSize 为 0 时任何任务都提交不进去，所以都必须大于 0
+func NewWorkerPool(server *MiniServer, workers, queueSize int) (*WorkerPool, error) {
+	var errs []error
+	if workers <= 0 {
+		errs = append(errs, fmt.Errorf("NewWorkerPool: worker 数必须大于 0，收到 %d", workers))
+	}
+	if queueSize <= 0 {
+		errs = append(errs, fmt.Errorf("NewWorkerPool: 队列长度必须大于 0，收到 %d", queueSize))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	p := &WorkerPool{server: server, queueSize: queueSize, queue: list.New()}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p, nil
+}
+
+// Submit 提交一个异步调用
+// 方法不存在、队列已满、方法并发已满或工作池已关闭时直接返回错误，不会得到 Future
+func (p *WorkerPool) Submit(ctx context.Context, method string, req interface{}) (*Future, error) {
+	r, err := p.server.resolve(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.acquire(method); err != nil {
+		return nil, err
+	}
+
+	t := &task{ctx: ctx, route: r, method: method, req: req, future: &Future{done: make(chan struct{})}}
+
+	p.mu.Lock()
+	switch {
+	case p.closed:
+		p.mu.Unlock()
+		r.release()
+		return nil, status.Errorf(codes.Unavailable, "工作池已关闭")
+	case p.queue.Len() >= p.queueSize:
+		p.mu.Unlock()
+		r.release()
+		return nil, status.Errorf(codes.ResourceExhausted, "队列已满（%d），请稍后再试", p.queueSize)
+	}
+	t.elem = p.queue.PushBack(t)
+	// ctx 取消时，如果任务还在排队就直接移除（回调需要 p.mu，所以不会早于这里解锁）
+	t.stopCtx = context.AfterFunc(ctx, func() { p.cancel(t) })
+	p.mu.Unlock()
+	p.cond.Signal()
+	return t.future, nil
+}
+
+func (p *WorkerPool) cancel(t *task) {
+	p.mu.Lock()
+	if t.elem == nil { // 已经被 worker 取走，交给 handler 自己感知 ctx
+		p.mu.Unlock()
+		return
+	}
+	p.queue.Remove(t.elem)
+	t.elem = nil
+	p.mu.Unlock()
+
+	t.route.release()
+	t.future.resolve(nil, status.FromContextError(t.ctx.Err()).Err())
+}
+
+func (p *WorkerPool) worker() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for p.queue.Len() == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.queue.Len() == 0 { // 已关闭且队列已清空
+			p.mu.Unlock()
+			return
+		}
+		t := p.queue.Remove(p.queue.Front()).(*task)
+		t.elem = nil
+		p.mu.Unlock()
+
+		t.stopCtx()
+		resp, err := t.route.run(t.ctx, t.method, t.req)
+		t.route.release()
+		t.future.resolve(resp, err)
+	}
+}
+
+// Len 当前排队中的任务数
+func (p *WorkerPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queue.Len()
+}
+
+// Close 停止接收新任务，等已排队的任务全部执行完
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.cond.Broadcast()
+	p.wg.Wait()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewWorkerPoolValidatesArguments(t *testing.T) {
+	tests := []struct {
+		workers, queueSize int
+		want               []string
+	}{
+		{1, 1, nil},
+		{0, 10, []string{"worker 数必须大于 0"}},
+		{-1, 10, []string{"worker 数必须大于 0"}},
+		{4, 0, []string{"队列长度必须大于 0"}},
+		{0, 0, []string{"worker 数必须大于 0", "队列长度必须大于 0"}},
+	}
+	for _, tt := range tests {
+		pool, err := NewWorkerPool(NewMiniServer(), tt.workers, tt.queueSize)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("NewWorkerPool(%d, %d) = %v", tt.workers, tt.queueSize, err)
+			} else {
+				pool.Close()
+			}
+			continue
+		}
+		if err == nil || pool != nil {
+			t.Errorf("NewWorkerPool(%d, %d) 应该出错", tt.workers, tt.queueSize)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("NewWorkerPool(%d, %d) = %v，缺少 %q", tt.workers, tt.queueSize, err, want)
+			}
+		}
+	}
+}
+
+func TestWithMaxConcurrencyRejectsNonPositive(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithMaxConcurrency(%d) 应该 panic", n)
+				}
+			}()
+			WithMaxConcurrency(n)
+		}()
+	}
+}
+
+func TestWorkerPoolBackpressure(t *testing.T) {
+	server := NewMiniServer()
+	release := make(chan struct{})
+	server.Handle("Slow", func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-release
+		return req, nil
+	}, WithMaxConcurrency(2))
+	server.Handle("Echo", echo)
+
+	pool, err := NewWorkerPool(server, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	defer close(release)
+
+	ctx := context.Background()
+	first, err := pool.Submit(ctx, "Slow", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Submit(ctx, "Slow", 2); err != nil {
+		t.Fatal(err)
+	}
+	// 方法并发上限 2：排队中和执行中的都算
+	if _, err := pool.Submit(ctx, "Slow", 3); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("超过方法并发上限得到 %v，期望 ResourceExhausted", err)
+	}
+	// 同步调用同样受限
+	if _, err := server.Call(ctx, "Slow", 4); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("同步调用得到 %v，期望 ResourceExhausted", err)
+	}
+
+	// 等 worker 取走第一个任务，再把队列填满
+	for deadline := time.Now().Add(time.Second); pool.Len() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("worker 没有取走任务，队列长度 %d", pool.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := pool.Submit(ctx, "Echo", 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Submit(ctx, "Echo", 6); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("队列已满得到 %v，期望 ResourceExhausted", err)
+	}
+
+	release <- struct{}{}
+	if resp, err := first.Wait(ctx); err != nil || resp != 1 {
+		t.Errorf("first.Wait() = %v, %v", resp, err)
+	}
+}
+
+func TestWorkerPoolCancelQueuedTask(t *testing.T) {
+	server := NewMiniServer()
+	release := make(chan struct{})
+	server.Handle("Slow", func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-release
+		return req, nil
+	})
+	pool, err := NewWorkerPool(server, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	defer close(release)
+
+	if _, err := pool.Submit(context.Background(), "Slow", 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	queued, err := pool.Submit(ctx, "Slow", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := queued.Wait(context.Background()); status.Code(err) != codes.Canceled {
+		t.Errorf("排队中被取消的任务得到 %v，期望 Canceled", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"frame_demo/middleware"
+)
+
+// ==================== Explain 模式 ====================
+// 请求被拒绝了，却不知道是链上哪个拦截器拒绝的？
+// Explain 和 Call 走完全相同的路径（同一个路由快照、并发上限、拦截器链），
+// 区别只是 context 里带了 Trace，route 会改用注册时一起编译好的追踪版本的链，
+// 返回每个拦截器的进入/退出、耗时、有没有调用下一个 handler。
+// 平时的 Call 只多一次 context 查找。
+
+// Explain 执行一次请求并返回时间线
+func (s *MiniServer) Explain(ctx context.Context, method string, req interface{}) (*middleware.Trace, interface{}, error) {
+	r, err := s.resolve(ctx, method)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trace := middleware.NewTrace(r.methodName(method))
+	resp, err := r.invoke(middleware.ContextWithTrace(ctx, trace), method, req)
+	trace.Finish(err)
+	return trace, resp, err
+}
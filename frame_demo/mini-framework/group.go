@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 路由级 & 分组中间件 ====================
+// Use 注册的是全局拦截器，所有方法共用同一套认证和限流。
+// 实际服务里经常需要：
+//   - 管理接口额外做权限校验：s.Group("admin.", AdminAuth())
+//   - 某个方法单独加拦截器：s.Handle("Upload", h, WithInterceptors(BodyLimit()))
+//   - 公开的健康检查不走认证：s.Handle("Health", h, SkipGlobalInterceptors())
+
+// Router MiniServer 和 Group 都可以注册方法
+type Router interface {
+	Handle(name string, handler Handler, opts ...RouteOption)
+}
+
+// RouteOption 注册单个方法时的选项
+type RouteOption func(*route)
+
+// WithInterceptors - 只作用于这个方法的拦截器
+func WithInterceptors(interceptors ...Interceptor) RouteOption {
+	return func(r *route) {
+		r.interceptors = append(r.interceptors, interceptors...)
+	}
+}
+
+// SkipGlobalInterceptors - 不经过 Use 注册的全局拦截器
+// 分组和路由自己的拦截器仍然生效
+func SkipGlobalInterceptors() RouteOption {
+	return func(r *route) {
+		r.skipGlobal = true
+	}
+}
+
+// Group 一组共享方法名前缀和拦截器的路由
+type Group struct {
+	router       Router
+	prefix       string
+	interceptors []Interceptor
+}
+
+// Group 创建路由分组，分组内注册的方法名会自动加上 prefix
+func (s *MiniServer) Group(prefix string, interceptors ...Interceptor) *Group {
+	return &Group{router: s, prefix: prefix, interceptors: interceptors}
+}
+
+// Group 嵌套分组，前缀和拦截器都会叠加
+func (g *Group) Group(prefix string, interceptors ...Interceptor) *Group {
+	return &Group{router: g, prefix: prefix, interceptors: interceptors}
+}
+
+// Handle 在分组内注册方法，分组拦截器排在路由拦截器之前
+func (g *Group) Handle(name string, handler Handler, opts ...RouteOption) {
+	groupOpts := append([]RouteOption{WithInterceptors(g.interceptors...)}, opts...)
+	g.router.Handle(g.prefix+name, handler, groupOpts...)
+}
+
+// ==================== 分组示例：管理接口 ====================
+
+// AdminAuthInterceptor 管理员校验（要求在全局认证之后执行）
+func AdminAuthInterceptor() Interceptor {
+	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		fmt.Println("👮 [AdminAuth] 检查管理员权限...")
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		if roles := md.Get("x-role"); len(roles) == 0 || roles[0] != "admin" {
+			return nil, status.Errorf(codes.PermissionDenied, "需要管理员权限")
+		}
+
+		fmt.Println("✅ [AdminAuth] 管理员权限通过")
+		return handler(ctx, req)
+	}
+}
+
+// Health 业务逻辑 - 公开接口，负载均衡器探活用
+func Health(ctx context.Context, req interface{}) (interface{}, error) {
+	return map[string]string{"status": "SERVING"}, nil
+}
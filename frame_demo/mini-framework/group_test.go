@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// orderInterceptor 把自己的名字记进 order，用来检查执行顺序
+func orderInterceptor(name string, order *[]string) Interceptor {
+	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		*order = append(*order, name)
+		return handler(ctx, req)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var order []string
+	server := NewMiniServer()
+	server.Use(orderInterceptor("global", &order))
+
+	admin := server.Group("admin.", orderInterceptor("admin", &order))
+	admin.Handle("CreateUser", echo, WithInterceptors(orderInterceptor("route", &order)))
+	admin.Group("user.", orderInterceptor("user", &order)).Handle("Delete", echo)
+	admin.Handle("Ping", echo, SkipGlobalInterceptors())
+	server.Handle("Health", echo, SkipGlobalInterceptors())
+	// 分组之后追加的全局拦截器同样作用于分组内的方法
+	server.Use(orderInterceptor("global2", &order))
+
+	tests := []struct {
+		method string
+		want   []string
+	}{
+		{"admin.CreateUser", []string{"global", "global2", "admin", "route"}},
+		{"admin.user.Delete", []string{"global", "global2", "admin", "user"}}, // 嵌套分组：前缀拼接，外层分组的拦截器在前
+		{"admin.Ping", []string{"admin"}},                                     // 跳过全局，分组拦截器仍然生效
+		{"Health", nil},
+	}
+	for _, tt := range tests {
+		order = nil
+		if resp, err := server.Call(context.Background(), tt.method, "req"); err != nil || resp != "req" {
+			t.Fatalf("Call(%s) = %v, %v", tt.method, resp, err)
+		}
+		if !reflect.DeepEqual(order, tt.want) {
+			t.Errorf("%s 执行顺序 = %v，期望 %v", tt.method, order, tt.want)
+		}
+	}
+
+	// 分组前缀只加在方法名上，没有注册不带前缀的版本
+	if _, ok := server.table.Load().lookup("CreateUser"); ok {
+		t.Error("CreateUser 不应该被注册")
+	}
+}
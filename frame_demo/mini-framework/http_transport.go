@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== HTTP JSON 传输层 ====================
+// 内部小工具不值得写 proto，但又想复用 MiniServer 的拦截器。
+// HTTPTransport 把注册的方法暴露成 POST /{method}，请求和响应都是 JSON：
+//
+//	curl -X POST localhost:8080/SayHelloTyped \
+//	     -H 'Authorization: valid-token-123' -d '{"name":"张三"}'
+//
+// HTTP 头会变成 incoming metadata，所以 AuthInterceptor 不用做任何修改；
+// status 错误按 gRPC-Gateway 的规则翻译成 HTTP 状态码（请求体超过上限例外，返回 413）。
+
+// defaultMaxBodyBytes 和 gRPC 默认的最大接收消息大小保持一致
+const defaultMaxBodyBytes = 4 << 20
+
+// skippedHeaders 只和 HTTP 连接本身有关的头，不放进 metadata
+var skippedHeaders = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"keep-alive":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// HTTPTransport 把 MiniServer 挂到 net/http 上
+type HTTPTransport struct {
+	server       *MiniServer
+	maxBodyBytes int64
+}
+
+func NewHTTPTransport(server *MiniServer) *HTTPTransport {
+	return &HTTPTransport{server: server, maxBodyBytes: defaultMaxBodyBytes}
+}
+
+// errorBody 错误响应，字段和 google.rpc.Status 的 JSON 形式一致
+type errorBody struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, status.Errorf(codes.Unimplemented, "只支持 POST，收到 %s", r.Method))
+		return
+	}
+
+	// 1. 找到方法（先找方法再读 body，不存在的方法不用解码）
+	method := methodFromPath(r.URL.Path)
+	rt, err := t.server.resolve(r.Context(), method)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	// 2. JSON -> 请求
+	req, err := t.decodeRequest(r, rt)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		// gRPC 里是 ResourceExhausted，但按 gRPC-Gateway 的映射会变成 429，客户端会当成限流去重试；
+		// 请求体太大重试也没用，明确返回 413
+		writeError(w, http.StatusRequestEntityTooLarge, status.Errorf(codes.ResourceExhausted, "请求体超过 %d 字节", tooLarge.Limit))
+		return
+	case err != nil:
+		writeStatus(w, err)
+		return
+	}
+
+	// 3. HTTP 头 -> incoming metadata，拦截器看到的和 gRPC 请求完全一样
+	md := metadata.MD{}
+	for name, values := range r.Header {
+		if key := strings.ToLower(name); !skippedHeaders[key] {
+			md.Append(key, values...)
+		}
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	// 4. 走完整的拦截器链
+	resp, err := rt.invoke(ctx, method, req)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		writeStatus(w, status.Errorf(codes.Internal, "响应编码失败: %v", err))
+	}
+}
+
+// methodFromPath URL 路径 -> 方法名，写法和 Call 一致：
+// POST /SayHello -> SayHello；POST /pkg.Service/Method 是完整的 gRPC 方法名，保留开头的 /，
+// 这样 s.Handle("/pkg.Service/*", h) 这类模式路由通过 HTTP 也能匹配到
+func methodFromPath(urlPath string) string {
+	method := strings.TrimPrefix(urlPath, "/")
+	if strings.Contains(method, "/") {
+		return urlPath
+	}
+	return method
+}
+
+// decodeRequest 按方法注册时的类型解码；普通 Handler 收到的是 map[string]interface{} 等 JSON 值
+// 请求体超过上限时原样返回 *http.MaxBytesError，由 ServeHTTP 翻译成 413
+func (t *HTTPTransport) decodeRequest(r *http.Request, rt *route) (interface{}, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, t.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "读取请求体失败: %v", err)
+	}
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+
+	var req interface{}
+	if rt.decode != nil {
+		req, err = rt.decode(data)
+	} else {
+		err = json.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "请求体不是合法的 JSON: %v", err)
+	}
+	return req, nil
+}
+
+// writeStatus 把 status 错误翻译成 HTTP 状态码（和 gRPC-Gateway 的映射一致）
+func writeStatus(w http.ResponseWriter, err error) {
+	writeError(w, runtime.HTTPStatusFromCode(status.Code(err)), err)
+}
+
+func writeError(w http.ResponseWriter, httpStatus int, err error) {
+	st := status.Convert(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(errorBody{Code: st.Code(), Message: st.Message()})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// post 直接调用 ServeHTTP，返回状态码和解码后的错误响应
+func post(transport http.Handler, path, body string, header http.Header) (*httptest.ResponseRecorder, errorBody) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	transport.ServeHTTP(rec, req)
+	var errBody errorBody
+	json.Unmarshal(rec.Body.Bytes(), &errBody)
+	return rec, errBody
+}
+
+func TestHTTPTransport_StatusMapping(t *testing.T) {
+	tests := []struct {
+		code       codes.Code
+		wantStatus int
+	}{
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		server := NewMiniServer()
+		server.Handle("Fail", func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(tt.code, "失败原因")
+		})
+		rec, body := post(NewHTTPTransport(server), "/Fail", "{}", nil)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s -> HTTP %d，期望 %d", tt.code, rec.Code, tt.wantStatus)
+		}
+		if body.Code != tt.code || body.Message != "失败原因" {
+			t.Errorf("%s: 响应体 = %+v", tt.code, body)
+		}
+	}
+}
+
+func TestHTTPTransport_HeadersToMetadata(t *testing.T) {
+	var md metadata.MD
+	server := NewMiniServer()
+	server.Handle("Echo", func(ctx context.Context, req interface{}) (interface{}, error) {
+		md, _ = metadata.FromIncomingContext(ctx)
+		return req, nil
+	})
+
+	rec, _ := post(NewHTTPTransport(server), "/Echo", `{"name":"张三"}`, http.Header{
+		"Authorization": {"valid-token-123"},
+		"X-Role":        {"admin", "auditor"},
+		"Connection":    {"keep-alive"},
+		"Te":            {"trailers"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", rec.Code, rec.Body)
+	}
+	if got := md.Get("authorization"); !reflect.DeepEqual(got, []string{"valid-token-123"}) {
+		t.Errorf("authorization = %v", got)
+	}
+	if got := md.Get("x-role"); !reflect.DeepEqual(got, []string{"admin", "auditor"}) {
+		t.Errorf("x-role = %v，期望保留所有值", got)
+	}
+	for _, key := range []string{"connection", "te"} {
+		if got := md.Get(key); len(got) != 0 {
+			t.Errorf("%s = %v，连接相关的头不应该进 metadata", key, got)
+		}
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp["name"] != "张三" {
+		t.Errorf("响应 = %s", rec.Body)
+	}
+}
+
+func TestHTTPTransport_Errors(t *testing.T) {
+	server := NewMiniServer()
+	called := false
+	server.Handle("Echo", func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return req, nil
+	})
+	transport := NewHTTPTransport(server)
+	transport.maxBodyBytes = 16
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		{"请求体超过上限", "/Echo", `{"name":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge, codes.ResourceExhausted},
+		{"不是 JSON", "/Echo", `{name}`, http.StatusBadRequest, codes.InvalidArgument},
+		{"方法不存在", "/Missing", `{}`, http.StatusNotFound, codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			rec, body := post(transport, tt.path, tt.body, nil)
+			if rec.Code != tt.wantStatus || body.Code != tt.wantCode {
+				t.Errorf("HTTP %d %s，期望 %d %s", rec.Code, body.Code, tt.wantStatus, tt.wantCode)
+			}
+			if called {
+				t.Error("请求被拒绝时不应该调用 handler")
+			}
+		})
+	}
+
+	// 只支持 POST
+	rec := httptest.NewRecorder()
+	transport.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/Echo", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET -> HTTP %d，Allow = %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -3,20 +3,31 @@ package main
 import (
 	"context"
 	"fmt"
+	"frame_demo/middleware"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // ==================== 第一步：理解拦截器设计模式 ====================
 
-// 业务处理函数类型
-type Handler func(ctx context.Context, req interface{}) (interface{}, error)
+// 业务处理函数类型：func(ctx context.Context, req interface{}) (interface{}, error)
+// 定义在共享的 middleware 包里，这样同一个中间件 ServerX 也能直接用
+type Handler = middleware.Handler
 
-// 拦截器类型
-type Interceptor func(ctx context.Context, req interface{}, handler Handler) (interface{}, error)
+// 拦截器类型：func(ctx context.Context, req interface{}, handler Handler) (interface{}, error)
+// 和 grpc.UnaryServerInterceptor 可以互相转换，见 middleware.ToUnaryServerInterceptor
+type Interceptor = middleware.Interceptor
 
 // ==================== 第二步：实现各种中间件 ====================
 
@@ -45,32 +56,13 @@ func AuthInterceptor() Interceptor {
 	}
 }
 
-// 2. 日志中间件
-func LoggingInterceptor() Interceptor {
-	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
-		start := time.Now()
-		fmt.Printf("📝 [Log] 请求开始: %T\n", req)
-
-		resp, err := handler(ctx, req)
-
-		duration := time.Since(start)
-		fmt.Printf("📝 [Log] 请求完成，耗时: %v，错误: %v\n", duration, err)
-
-		return resp, err
-	}
-}
+// 日志、限流这类通用中间件直接用 middleware 包里的 Logging()、RateLimit()，ServerX 用的也是同一份
 
-// 3. 限流中间件
-func RateLimitInterceptor() Interceptor {
-	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
-		fmt.Println("🚦 [RateLimit] 开始限流检查...")
-
-		// 模拟限流逻辑
-		time.Sleep(10 * time.Millisecond)
-
-		fmt.Println("✅ [RateLimit] 限流检查通过")
-		return handler(ctx, req)
-	}
+// 2. gRPC 风格的审计拦截器（签名是 grpc.UnaryServerInterceptor）
+// 现成的 gRPC 中间件不用重写，用 middleware.FromUnaryServerInterceptor 转换即可
+func AuditGRPCInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	fmt.Printf("🧾 [Audit] 调用 %s\n", info.FullMethod)
+	return handler(ctx, req)
 }
 
 // ==================== 第三步：实现拦截器链 ====================
@@ -94,37 +86,171 @@ func Chain(interceptors ...Interceptor) Interceptor {
 
 // ==================== 第四步：框架核心（模拟 grpc.NewServer） ====================
 
+// compile 把拦截器链和业务处理函数“编译”成一个 Handler
+// 和 Chain 的思路完全一样，区别是只在注册时做一次，而不是每次调用都重新构建闭包
+func compile(interceptors []Interceptor, handler Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler
+}
+
+// miniServiceName 映射成 gRPC 完整方法名时使用的服务名，如 /mini.MiniServer/SayHello
+const miniServiceName = "mini.MiniServer"
+
+// route 一个已注册的方法
+type route struct {
+	name         string                                 // 注册时的方法名或模式（如 user.*）
+	fullMethod   string                                 // gRPC 风格的完整方法名，gRPC 拦截器从 UnaryServerInfo.FullMethod 读到的就是它；模式路由为空
+	handler      Handler                                // 原始业务处理函数
+	interceptors []Interceptor                          // 分组 + 路由自己的拦截器，排在全局拦截器之后
+	skipGlobal   bool                                   // 不经过全局拦截器（如公开的健康检查）
+	decode       func(data []byte) (interface{}, error) // HTTP 传输层把 JSON 解码成请求，为空时解码成 map
+	limiter      chan struct{}                          // 并发上限（信号量），为空表示不限制；重新编译时共享同一个
+	compiled     Handler                                // 拦截器链 + 业务处理函数
+	traced       Handler                                // 同一条链的追踪版本，context 里有 Trace 时使用（Explain）
+}
+
+// chain 这个方法实际生效的拦截器：全局 + 分组/路由
+func (r *route) chain(global []Interceptor) []Interceptor {
+	if r.skipGlobal {
+		return r.interceptors
+	}
+	return append(append([]Interceptor(nil), global...), r.interceptors...)
+}
+
+// compileWith 用给定的全局拦截器重新编译，返回新的 route（route 发布后不可变）
+func (r *route) compileWith(global []Interceptor) *route {
+	next := *r
+	chain := r.chain(global)
+	next.compiled = compile(chain, r.handler)
+	next.traced = compile(middleware.Instrument(chain...), r.handler)
+	return &next
+}
+
+// routeTable 路由快照，一旦发布就不再修改（copy-on-write）
+// 读请求拿到的永远是一个完整、一致的快照，所以 Call 完全不需要加锁
+type routeTable struct {
+	interceptors       []Interceptor
+	routes             map[string]*route // 精确匹配
+	patterns           []*route          // 模式匹配，越具体的越靠前
+	fallback           *route            // 都匹配不上时的默认处理器
+	notFoundHooks      []NotFoundHook
+	streamInterceptors []StreamInterceptor
+	streams            map[string]*streamRoute
+}
+
+// clone 复制一份快照用于修改，route 本身不可变，可以共享
+func (t *routeTable) clone() *routeTable {
+	next := &routeTable{
+		interceptors:       append([]Interceptor(nil), t.interceptors...),
+		routes:             make(map[string]*route, len(t.routes)),
+		patterns:           append([]*route(nil), t.patterns...),
+		fallback:           t.fallback,
+		notFoundHooks:      append([]NotFoundHook(nil), t.notFoundHooks...),
+		streamInterceptors: append([]StreamInterceptor(nil), t.streamInterceptors...),
+		streams:            make(map[string]*streamRoute, len(t.streams)),
+	}
+	for name, r := range t.routes {
+		next.routes[name] = r
+	}
+	for name, r := range t.streams {
+		next.streams[name] = r
+	}
+	return next
+}
+
 type MiniServer struct {
-	interceptors []Interceptor
-	handlers     map[string]Handler
+	mu    sync.Mutex                 // 只保护写（Use/Handle 之间互斥）
+	table atomic.Pointer[routeTable] // 读（Call）无锁
 }
 
 func NewMiniServer() *MiniServer {
-	return &MiniServer{
-		handlers: make(map[string]Handler),
-	}
+	s := &MiniServer{}
+	s.table.Store(&routeTable{routes: make(map[string]*route), streams: make(map[string]*streamRoute)})
+	return s
+}
+
+// update 在锁内复制当前快照、修改、再原子地发布新快照
+func (s *MiniServer) update(modify func(t *routeTable)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.table.Load().clone()
+	modify(next)
+	s.table.Store(next)
 }
 
 // 添加拦截器（类似 grpc.UnaryInterceptor）
+// 拦截器变了，所有已注册方法的链都要重新编译
 func (s *MiniServer) Use(interceptors ...Interceptor) {
-	s.interceptors = append(s.interceptors, interceptors...)
+	s.update(func(t *routeTable) {
+		t.interceptors = append(t.interceptors, interceptors...)
+		for name, r := range t.routes {
+			t.routes[name] = r.compileWith(t.interceptors)
+		}
+		for i, r := range t.patterns {
+			t.patterns[i] = r.compileWith(t.interceptors)
+		}
+		if t.fallback != nil {
+			t.fallback = t.fallback.compileWith(t.interceptors)
+		}
+	})
 }
 
-// 注册处理器
-func (s *MiniServer) Handle(name string, handler Handler) {
-	s.handlers[name] = handler
+// 注册处理器（服务运行中也可以安全地注册）
+// 执行顺序：全局拦截器 -> 分组拦截器 -> 路由拦截器 -> 业务处理函数
+// name 含 * ? [ 时是模式路由，语法同 path.Match，如 user.* 或 /pkg.Service/*
+func (s *MiniServer) Handle(name string, handler Handler, opts ...RouteOption) {
+	r := &route{name: name, handler: handler}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if !isPattern(name) {
+		r.fullMethod = middleware.FullMethod(miniServiceName, name)
+	} else if _, err := path.Match(name, ""); err != nil {
+		// 和 http.ServeMux 一样，注册阶段的编程错误直接 panic
+		panic(fmt.Sprintf("MiniServer: 非法的路由模式 %q: %v", name, err))
+	}
+
+	s.update(func(t *routeTable) {
+		r := r.compileWith(t.interceptors)
+		if r.fullMethod != "" {
+			t.routes[name] = r
+			return
+		}
+		t.addPattern(r)
+	})
 }
 
 // 执行请求（模拟真实的 gRPC 调用）
 func (s *MiniServer) Call(ctx context.Context, method string, req interface{}) (interface{}, error) {
-	handler, ok := s.handlers[method]
-	if !ok {
-		return nil, status.Errorf(codes.NotFound, "方法不存在: %s", method)
+	r, err := s.resolve(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	return r.invoke(ctx, method, req)
+}
+
+// invoke 占用并发名额后执行
+func (r *route) invoke(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	if err := r.acquire(method); err != nil {
+		return nil, err
 	}
+	defer r.release()
+	return r.run(ctx, method, req)
+}
 
-	// 应用拦截器链
-	chain := Chain(s.interceptors...)
-	return chain(ctx, req, handler)
+// run 拦截器链在注册时已经编译好，这里直接调用
+func (r *route) run(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	handler := r.compiled
+	if middleware.TraceFromContext(ctx) != nil {
+		handler = r.traced
+	}
+	return handler(middleware.WithMethod(ctx, r.methodName(method)), req)
 }
 
 // ==================== 第五步：业务代码（现在变得很干净） ====================
@@ -175,7 +301,8 @@ func (s *原始Server) SayHello(ctx context.Context, req interface{}) (interface
 }
 
 func main() {
-	fmt.Println("=== 🚀 欢迎来到框架开发的世界 ===\n")
+	fmt.Println("=== 🚀 欢迎来到框架开发的世界 ===")
+	fmt.Println()
 
 	// ========== 使用框架版本 ==========
 	fmt.Println("✨ 框架版本（推荐）：")
@@ -183,9 +310,9 @@ func main() {
 
 	// 声明式配置中间件
 	server.Use(
-		LoggingInterceptor(),
+		middleware.Logging(),
 		AuthInterceptor(),
-		RateLimitInterceptor(),
+		middleware.RateLimit(100, 20), // 每秒 100 个请求，最多攒 20 个
 	)
 
 	// 注册业务逻辑
@@ -205,6 +332,178 @@ func main() {
 		fmt.Printf("响应: %+v\n\n", resp)
 	}
 
+	// ========== 类型安全的处理函数 ==========
+	fmt.Println("🧩 泛型处理函数：")
+	HandleTyped(server, "SayHelloTyped", SayHelloTyped)
+
+	reply, err := CallTyped[*HelloRequest, *HelloReply](ctx, server, "SayHelloTyped", &HelloRequest{Name: "张三"})
+	if err != nil {
+		log.Printf("请求失败: %v", err)
+	} else {
+		fmt.Printf("响应: %s\n", reply.Message)
+	}
+
+	// 请求类型不对，在进入业务代码之前就被拒绝
+	if _, err := server.Call(ctx, "SayHelloTyped", map[string]string{"name": "张三"}); err != nil {
+		fmt.Printf("类型错误: %v\n\n", err)
+	}
+
+	// ========== 分组 & 路由级中间件 ==========
+	fmt.Println("🗂️  分组与路由级中间件：")
+	admin := server.Group("admin.", AdminAuthInterceptor())
+	admin.Handle("CreateUser", CreateUser业务逻辑)
+	server.Handle("Health", Health, SkipGlobalInterceptors())
+
+	// 普通用户调用管理接口：全局认证通过，但分组的管理员校验不通过
+	if _, err := server.Call(ctx, "admin.CreateUser", nil); err != nil {
+		fmt.Printf("管理接口: %v\n", err)
+	}
+
+	// Explain：看看到底是哪个拦截器拒绝的
+	if trace, _, err := server.Explain(ctx, "admin.CreateUser", nil); err != nil {
+		fmt.Printf("🔍 Explain:\n%s", trace.Explain())
+	}
+
+	// 健康检查不带任何认证信息也能调用
+	if resp, err := server.Call(context.Background(), "Health", nil); err == nil {
+		fmt.Printf("健康检查: %+v\n\n", resp)
+	}
+
+	// ========== 模式路由 & 兜底 ==========
+	fmt.Println("🧭 模式路由与兜底：")
+	server.Handle("user.*", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "user 模块处理了 " + middleware.MethodFromContext(ctx), nil
+	}, SkipGlobalInterceptors())
+	server.OnNotFound(func(ctx context.Context, method string) {
+		fmt.Printf("🔎 [NotFound] 没有找到 %s，尝试兜底\n", method)
+	})
+	server.Fallback(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Errorf(codes.Unimplemented, "%s 暂未实现", middleware.MethodFromContext(ctx))
+	}, SkipGlobalInterceptors())
+
+	for _, method := range []string{"user.Delete", "order.Create"} {
+		resp, err := server.Call(ctx, method, nil)
+		fmt.Printf("%s -> %v %v\n", method, resp, err)
+	}
+	for _, r := range server.Routes() {
+		fmt.Printf("   %-18s %-8s 拦截器: %d\n", r.Name, r.Kind, r.Interceptors)
+	}
+	fmt.Println()
+
+	// ========== 异步分发 ==========
+	fmt.Println("⏳ 异步分发（1 个 worker，队列长度 2，Report 最多 2 个并发）：")
+	server.Handle("Report", func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond) // 模拟生成报表
+		return fmt.Sprintf("报表 %v 已生成", req), nil
+	}, SkipGlobalInterceptors(), WithMaxConcurrency(2))
+
+	pool, err := NewWorkerPool(server, 1, 2)
+	if err != nil {
+		log.Fatalf("创建工作池失败: %v", err)
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	var futures []*Future
+	for i := 1; i <= 3; i++ {
+		taskCtx := ctx
+		if i == 2 {
+			taskCtx = cancelCtx // 第 2 个任务排队时就被取消
+		}
+		future, err := pool.Submit(taskCtx, "Report", i)
+		if err != nil {
+			fmt.Printf("提交任务 %d 被拒绝: %v\n", i, err)
+			continue
+		}
+		futures = append(futures, future)
+	}
+	cancel()
+	for _, future := range futures {
+		resp, err := future.Wait(ctx)
+		fmt.Printf("结果: %v %v\n", resp, err)
+	}
+	pool.Close()
+	fmt.Println()
+
+	// ========== 客户端中间件 ==========
+	fmt.Println("🔁 客户端中间件（超时 + 重试 + 对冲）：")
+	var attempts atomic.Int32
+	server.Handle("Inventory.Get", func(ctx context.Context, req interface{}) (interface{}, error) {
+		n := attempts.Add(1)
+		switch n {
+		case 1, 2: // 前两次模拟下游抖动
+			return nil, status.Errorf(codes.Unavailable, "库存服务暂时不可用")
+		case 3: // 第三次特别慢，由对冲请求兜住
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return fmt.Sprintf("第 %d 次调用返回库存 42", n), nil
+	}, SkipGlobalInterceptors())
+
+	idempotent := middleware.MethodMatcher("*.Get", "*.List")
+	call := middleware.WrapInvoker(server.Call,
+		middleware.Timeout(middleware.TimeoutPolicy{Default: 500 * time.Millisecond}),
+		middleware.Retry(middleware.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			Idempotent:     idempotent,
+			Budget:         middleware.NewRetryBudget(10, 0.1),
+		}),
+		middleware.Hedge(middleware.HedgePolicy{Delay: 30 * time.Millisecond, Idempotent: idempotent}),
+	)
+	resp, err = call(ctx, "Inventory.Get", nil)
+	fmt.Printf("结果: %v %v（服务端共收到 %d 次调用）\n\n", resp, err, attempts.Load())
+
+	// ========== 共享中间件 ==========
+	fmt.Println("🔌 共享中间件：")
+	server.Handle("Panic", func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("数据库连接为空")
+	}, WithInterceptors(
+		middleware.FromUnaryServerInterceptor(AuditGRPCInterceptor), // gRPC 拦截器 -> MiniServer
+		middleware.Recovery(), // 共享包里的中间件，ServerX 也在用
+	))
+	if _, err := server.Call(ctx, "Panic", nil); err != nil {
+		fmt.Printf("panic 被转换成错误: %v\n\n", err)
+	}
+
+	// ========== HTTP JSON 传输层 ==========
+	fmt.Println("🌐 HTTP JSON 传输层：")
+	httpServer := httptest.NewServer(NewHTTPTransport(server))
+	for _, token := range []string{"valid-token-123", ""} {
+		httpReq, _ := http.NewRequest(http.MethodPost, httpServer.URL+"/SayHelloTyped", strings.NewReader(`{"name":"李四"}`))
+		httpReq.Header.Set("Authorization", token)
+		httpResp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			log.Printf("HTTP 请求失败: %v", err)
+			continue
+		}
+		body, _ := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		fmt.Printf("POST /SayHelloTyped -> %d %s", httpResp.StatusCode, body)
+	}
+	httpServer.Close()
+	fmt.Println()
+
+	// ========== 流式处理 ==========
+	fmt.Println("🌊 流式处理：")
+	server.UseStream(StreamLoggingInterceptor("外层"), StreamLoggingInterceptor("内层"), MessageCounterInterceptor())
+	server.HandleStream("Chat", Chat)
+
+	stream, err := server.OpenStream(ctx, "Chat")
+	if err != nil {
+		log.Printf("打开流失败: %v", err)
+	} else {
+		for _, text := range []string{"你好", "再见"} {
+			stream.Send(text)
+			msg, _ := stream.Recv()
+			fmt.Printf("收到: %v\n", msg)
+		}
+		stream.CloseSend()
+		_, err := stream.Recv() // io.EOF 表示服务端正常结束
+		fmt.Printf("流结束: %v\n\n", err)
+	}
+
 	// ========== 对比原始版本 ==========
 	fmt.Println("❌ 原始版本（不推荐）：")
 	original := &原始Server{}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 预编译调用链 & 并发安全 ====================
+// 早期版本的 Call 每次都执行 Chain(s.interceptors...)，重新构建一整棵闭包；
+// handlers 也是一个没加锁的 map，服务运行中注册新方法会产生数据竞争。
+// 现在链在 Use/Handle 时编译一次，路由表用 copy-on-write 快照发布。
+//
+//	go test -race -run TestConcurrentHandleAndCall .
+//	go test -bench BenchmarkCall -benchmem .
+
+// passThrough 什么都不做的拦截器，用来测量框架本身的开销（不打印日志）
+func passThrough() Interceptor {
+	return func(ctx context.Context, req interface{}, handler Handler) (interface{}, error) {
+		return handler(ctx, req)
+	}
+}
+
+func echo(ctx context.Context, req interface{}) (interface{}, error) {
+	return req, nil
+}
+
+func benchInterceptors() []Interceptor {
+	return []Interceptor{passThrough(), passThrough(), passThrough(), passThrough(), passThrough()}
+}
+
+// perCallServer 旧的实现方式：每次调用都重新构建拦截器链，仅用于对比
+type perCallServer struct {
+	interceptors []Interceptor
+	handlers     map[string]Handler
+}
+
+func (s *perCallServer) Call(ctx context.Context, method string, req interface{}) (interface{}, error) {
+	return Chain(s.interceptors...)(ctx, req, s.handlers[method])
+}
+
+func benchmarkCall(b *testing.B, call func(context.Context, string, interface{}) (interface{}, error)) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := call(ctx, "Echo", "ping"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkCall_PerCallChain(b *testing.B) {
+	server := &perCallServer{interceptors: benchInterceptors(), handlers: map[string]Handler{"Echo": echo}}
+	benchmarkCall(b, server.Call)
+}
+
+func BenchmarkCall_Precompiled(b *testing.B) {
+	server := NewMiniServer()
+	server.Use(benchInterceptors()...)
+	server.Handle("Echo", echo)
+	benchmarkCall(b, server.Call)
+}
+
+// TestConcurrentHandleAndCall 一边处理请求，一边注册新方法、追加拦截器；配合 -race 运行
+func TestConcurrentHandleAndCall(t *testing.T) {
+	server := NewMiniServer()
+	server.Handle("Echo", echo)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				resp, err := server.Call(context.Background(), "Echo", j)
+				if err != nil || resp != j {
+					t.Errorf("Call(Echo, %d) = %v, %v", j, resp, err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			server.Handle(fmt.Sprintf("Method%d", j), echo)
+			if j%10 == 0 {
+				server.Use(passThrough())
+			}
+		}
+	}()
+	wg.Wait()
+
+	table := server.table.Load()
+	if got := len(table.routes); got != 101 {
+		t.Errorf("注册了 %d 个方法，期望 101", got)
+	}
+	if got := len(table.interceptors); got != 10 {
+		t.Errorf("追加了 %d 个拦截器，期望 10", got)
+	}
+	// 后注册的方法也要经过之前追加的拦截器，说明 Use 重新编译了已有的路由
+	if resp, err := server.Call(context.Background(), "Method0", "ok"); err != nil || resp != "ok" {
+		t.Errorf("Call(Method0) = %v, %v", resp, err)
+	}
+}
+
+// TestExplainUsesRouteChain Explain 和 Call 走同一个路由：同样的拦截器，同样受并发上限限制
+func TestExplainUsesRouteChain(t *testing.T) {
+	server := NewMiniServer()
+	server.Use(passThrough())
+	entered, release := make(chan struct{}), make(chan struct{})
+	server.Handle("Slow", func(ctx context.Context, req interface{}) (interface{}, error) {
+		entered <- struct{}{}
+		<-release
+		return req, nil
+	}, WithMaxConcurrency(1), WithInterceptors(passThrough()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Call(context.Background(), "Slow", 1)
+	}()
+	<-entered
+
+	// 名额被 Call 占着，Explain 同样被拒绝
+	trace, _, err := server.Explain(context.Background(), "Slow", 2)
+	if status.Code(err) != codes.ResourceExhausted || trace.Code != codes.ResourceExhausted.String() {
+		t.Errorf("Explain = %v，Trace.Code = %s，期望 ResourceExhausted", err, trace.Code)
+	}
+	close(release)
+	<-done
+
+	go func() { <-entered }()
+	trace, resp, err := server.Explain(context.Background(), "Slow", 3)
+	if err != nil || resp != 3 {
+		t.Fatalf("Explain = %v, %v", resp, err)
+	}
+	var names []string
+	for _, span := range trace.Spans {
+		names = append(names, span.Name)
+	}
+	// 全局 + 路由拦截器 + 业务 handler
+	if want := []string{"passThrough", "passThrough", "handler"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("spans = %v，期望 %v", names, want)
+	}
+	if trace.Method != "/mini.MiniServer/Slow" {
+		t.Errorf("Method = %q", trace.Method)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"frame_demo/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 模式路由 & 兜底处理 ====================
+// 精确匹配之外，MiniServer 还可以当作通用的分发器：
+//   - 模式路由：s.Handle("user.*", h) / s.Handle("/pkg.Service/*", h)
+//   - 找不到方法时的钩子：s.OnNotFound(func(ctx, method) { 按需加载插件并 s.Handle(...) })
+//   - 兜底处理器：s.Fallback(h)，比如转发给另一个服务
+// 查找顺序：精确匹配 -> 模式匹配（越具体越优先） -> 钩子 -> 再查一次 -> 兜底 -> NotFound
+
+// NotFoundHook 方法找不到时调用，可以在这里记录日志，也可以动态注册 handler
+type NotFoundHook func(ctx context.Context, method string)
+
+// isPattern 方法名里有 path.Match 的通配符就按模式处理
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// specificity 模式的具体程度：第一个通配符之前的字面量越长越具体
+func specificity(pattern string) int {
+	return strings.IndexAny(pattern, "*?[")
+}
+
+// addPattern 插入或替换模式路由，保持按具体程度排序（相同时先注册的优先）
+func (t *routeTable) addPattern(r *route) {
+	for i, existing := range t.patterns {
+		if existing.name == r.name {
+			t.patterns[i] = r
+			return
+		}
+	}
+	t.patterns = append(t.patterns, r)
+	sort.SliceStable(t.patterns, func(i, j int) bool {
+		return specificity(t.patterns[i].name) > specificity(t.patterns[j].name)
+	})
+}
+
+// lookup 精确匹配优先，然后按顺序尝试模式
+func (t *routeTable) lookup(method string) (*route, bool) {
+	if r, ok := t.routes[method]; ok {
+		return r, true
+	}
+	for _, r := range t.patterns {
+		if ok, _ := path.Match(r.name, method); ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// resolve 完整的查找流程（包含钩子和兜底）
+func (s *MiniServer) resolve(ctx context.Context, method string) (*route, error) {
+	t := s.table.Load()
+	if r, ok := t.lookup(method); ok {
+		return r, nil
+	}
+
+	if len(t.notFoundHooks) > 0 {
+		for _, hook := range t.notFoundHooks {
+			hook(ctx, method)
+		}
+		// 钩子可能刚注册了 handler，重新加载快照再找一次
+		t = s.table.Load()
+		if r, ok := t.lookup(method); ok {
+			return r, nil
+		}
+	}
+
+	if t.fallback != nil {
+		return t.fallback, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "方法不存在: %s", method)
+}
+
+// methodName 传给拦截器的完整方法名：模式路由和兜底用实际调用的方法名
+func (r *route) methodName(method string) string {
+	if r.fullMethod != "" {
+		return r.fullMethod
+	}
+	return middleware.FullMethod(miniServiceName, method)
+}
+
+// Fallback 设置兜底处理器，同样经过全局拦截器
+func (s *MiniServer) Fallback(handler Handler, opts ...RouteOption) {
+	r := &route{name: "*", handler: handler}
+	for _, opt := range opts {
+		opt(r)
+	}
+	s.update(func(t *routeTable) {
+		t.fallback = r.compileWith(t.interceptors)
+	})
+}
+
+// OnNotFound 注册方法找不到时的钩子，按注册顺序调用
+func (s *MiniServer) OnNotFound(hooks ...NotFoundHook) {
+	s.update(func(t *routeTable) {
+		t.notFoundHooks = append(t.notFoundHooks, hooks...)
+	})
+}
+
+// RouteInfo 路由信息，用于调试和生成文档
+type RouteInfo struct {
+	Name         string // 方法名或模式
+	Kind         string // exact / pattern / stream / fallback
+	Interceptors int    // 实际生效的拦截器数量
+	SkipGlobal   bool
+}
+
+// Routes 列出当前所有路由：精确和流式按名字排序，模式按匹配优先级，兜底在最后
+func (s *MiniServer) Routes() []RouteInfo {
+	t := s.table.Load()
+	info := func(r *route, kind string) RouteInfo {
+		return RouteInfo{Name: r.name, Kind: kind, Interceptors: len(r.chain(t.interceptors)), SkipGlobal: r.skipGlobal}
+	}
+
+	var routes []RouteInfo
+	for _, r := range t.routes {
+		routes = append(routes, info(r, "exact"))
+	}
+	for name := range t.streams {
+		routes = append(routes, RouteInfo{Name: name, Kind: "stream", Interceptors: len(t.streamInterceptors)})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+
+	for _, r := range t.patterns {
+		routes = append(routes, info(r, "pattern"))
+	}
+	if t.fallback != nil {
+		routes = append(routes, info(t.fallback, "fallback"))
+	}
+	return routes
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"frame_demo/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// named 返回注册名的 handler，用来判断请求落到了哪个路由
+func named(name string) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return name, nil
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"*", 0},
+		{"user.*", 5},
+		{"user.admin.*", 11},
+		{"/pkg.Service/*", 13},
+		{"user.Get?", 8},
+		{"user.[GL]et", 5},
+	}
+	for _, tt := range tests {
+		if got := specificity(tt.pattern); got != tt.want {
+			t.Errorf("specificity(%q) = %d，期望 %d", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	server := NewMiniServer()
+	server.Handle("user.*", named("user.*"))
+	server.Handle("*.Get", named("*.Get"))
+	server.Handle("user.admin.*", named("user.admin.*"))
+	server.Handle("user.Get", named("user.Get"))
+	server.Handle("/pkg.Service/*", named("/pkg.Service/*"))
+	server.Handle("order.?et", named("order.?et"))
+	server.Handle("order.*", named("order.*")) // 和 order.?et 一样具体，先注册的优先
+
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{"user.Get", "user.Get"},              // 精确匹配优先于所有模式
+		{"user.admin.Create", "user.admin.*"}, // 更具体的模式优先
+		{"user.Delete", "user.*"},
+		{"order.Get", "order.?et"}, // 具体程度相同，按注册顺序
+		{"order.Create", "order.*"},
+		{"item.Get", "*.Get"},
+		{"/pkg.Service/Echo", "/pkg.Service/*"},
+		{"/pkg.Service/a/b", ""}, // * 不匹配 /
+		{"item.List", ""},
+	}
+	for _, tt := range tests {
+		r, ok := server.table.Load().lookup(tt.method)
+		got := ""
+		if ok {
+			got = r.name
+		}
+		if got != tt.want {
+			t.Errorf("lookup(%q) = %q，期望 %q", tt.method, got, tt.want)
+		}
+	}
+
+	// 重复注册同一个模式是替换，不会多出一条
+	server.Handle("user.*", named("user.* v2"))
+	if resp, _ := server.Call(context.Background(), "user.Delete", nil); resp != "user.* v2" {
+		t.Errorf("替换后 user.Delete -> %v，期望 user.* v2", resp)
+	}
+	if n := len(server.table.Load().patterns); n != 6 {
+		t.Errorf("模式路由有 %d 条，期望 6 条", n)
+	}
+}
+
+func TestHandleRejectsBadPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("非法的路由模式应该 panic")
+		}
+	}()
+	NewMiniServer().Handle("user.[", named("bad"))
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		hook      bool // OnNotFound 里注册 plugin.*
+		fallback  bool
+		method    string
+		want      interface{}
+		wantCode  codes.Code
+		wantHooks int
+	}{
+		{name: "找不到", method: "plugin.Run", wantCode: codes.NotFound},
+		{name: "钩子注册后再查一次", hook: true, fallback: true, method: "plugin.Run", want: "plugin.*", wantHooks: 1},
+		{name: "钩子没注册到，走兜底", hook: true, fallback: true, method: "order.Create", want: "/mini.MiniServer/order.Create", wantHooks: 1},
+		{name: "已注册的方法不调用钩子", hook: true, method: "Echo", want: "Echo"},
+		{name: "只有兜底", fallback: true, method: "order.Create", want: "/mini.MiniServer/order.Create"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewMiniServer()
+			server.Handle("Echo", named("Echo"))
+			hooks := 0
+			if tt.hook {
+				server.OnNotFound(func(ctx context.Context, method string) {
+					hooks++
+					if strings.HasPrefix(method, "plugin.") {
+						server.Handle("plugin.*", named("plugin.*"))
+					}
+				})
+			}
+			if tt.fallback {
+				server.Fallback(func(ctx context.Context, req interface{}) (interface{}, error) {
+					return middleware.MethodFromContext(ctx), nil
+				})
+			}
+
+			resp, err := server.Call(context.Background(), tt.method, nil)
+			if status.Code(err) != tt.wantCode || resp != tt.want {
+				t.Errorf("Call(%s) = %v, %v，期望 %v, %v", tt.method, resp, err, tt.want, tt.wantCode)
+			}
+			if hooks != tt.wantHooks {
+				t.Errorf("钩子调用了 %d 次，期望 %d 次", hooks, tt.wantHooks)
+			}
+
+			// 钩子注册的路由之后直接命中，不再调用钩子
+			if tt.want == "plugin.*" {
+				server.Call(context.Background(), "plugin.Stop", nil)
+				if hooks != 1 {
+					t.Errorf("第二次调用又触发了钩子")
+				}
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	server := NewMiniServer()
+	server.Use(passThrough(), passThrough())
+	server.Handle("SayHello", echo)
+	server.Handle("Health", echo, SkipGlobalInterceptors())
+	server.Group("admin.", passThrough()).Handle("CreateUser", echo)
+	server.Handle("user.*", echo)
+	server.Handle("user.admin.*", echo, SkipGlobalInterceptors())
+	server.HandleStream("Chat", func(stream ServerStream) error { return nil })
+	server.Fallback(echo)
+
+	want := []RouteInfo{
+		{Name: "Chat", Kind: "stream"},
+		{Name: "Health", Kind: "exact", SkipGlobal: true},
+		{Name: "SayHello", Kind: "exact", Interceptors: 2},
+		{Name: "admin.CreateUser", Kind: "exact", Interceptors: 3},
+		{Name: "user.admin.*", Kind: "pattern", SkipGlobal: true},
+		{Name: "user.*", Kind: "pattern", Interceptors: 2},
+		{Name: "*", Kind: "fallback", Interceptors: 2},
+	}
+	if got := server.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() =\n%+v\n期望\n%+v", got, want)
+	}
+}
+
+func TestHTTPTransport_MethodFromPath(t *testing.T) {
+	server := NewMiniServer()
+	server.Handle("SayHello", named("SayHello"))
+	server.Handle("admin.CreateUser", named("admin.CreateUser"))
+	server.Handle("/pkg.Service/*", named("/pkg.Service/*"))
+	httpServer := httptest.NewServer(NewHTTPTransport(server))
+	defer httpServer.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       string
+	}{
+		{"/SayHello", http.StatusOK, "SayHello"},
+		{"/admin.CreateUser", http.StatusOK, "admin.CreateUser"},
+		{"/pkg.Service/Echo", http.StatusOK, "/pkg.Service/*"}, // 完整 gRPC 方法名，和 Call 的写法一致
+		{"/pkg.Other/Echo", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(httpServer.URL+tt.path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus || got != tt.want {
+			t.Errorf("POST %s -> %d %q，期望 %d %q", tt.path, resp.StatusCode, got, tt.wantStatus, tt.want)
+		}
+	}
+}
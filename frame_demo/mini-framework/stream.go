@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"frame_demo/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 流式处理（模拟 grpc.StreamHandler） ====================
+// 一元调用是“一个请求 -> 一个响应”，流式调用是在一条连接上反复 Send/Recv。
+// 拦截器的思路完全一样，只是包住的是整个流：
+//   - 打开流时执行拦截器的前半段，handler 返回（流关闭）后执行后半段
+//   - 想处理每一条消息，就包一层 ServerStream，重写 Send/Recv（和 grpc 的 WrappedServerStream 一样）
+
+// ServerStream 服务端看到的流（对应 grpc.ServerStream）
+type ServerStream interface {
+	Context() context.Context
+	Send(msg interface{}) error
+	// Recv 客户端调用 CloseSend 后返回 io.EOF
+	Recv() (interface{}, error)
+}
+
+// StreamHandler 流式业务处理函数，返回即表示流结束
+type StreamHandler func(stream ServerStream) error
+
+// StreamInterceptor 流拦截器（对应 grpc.StreamServerInterceptor）
+type StreamInterceptor func(stream ServerStream, handler StreamHandler) error
+
+// WrappedStream 包装 ServerStream，只重写需要的方法，其余直接透传
+type WrappedStream struct {
+	ServerStream
+	Ctx    context.Context                            // 不为空时替换 Context()
+	OnSend func(msg interface{}) (interface{}, error) // 发送前改写消息
+	OnRecv func(msg interface{}) (interface{}, error) // 收到后改写消息
+}
+
+func (w *WrappedStream) Context() context.Context {
+	if w.Ctx != nil {
+		return w.Ctx
+	}
+	return w.ServerStream.Context()
+}
+
+func (w *WrappedStream) Send(msg interface{}) error {
+	if w.OnSend != nil {
+		var err error
+		if msg, err = w.OnSend(msg); err != nil {
+			return err
+		}
+	}
+	return w.ServerStream.Send(msg)
+}
+
+func (w *WrappedStream) Recv() (interface{}, error) {
+	msg, err := w.ServerStream.Recv()
+	if err != nil || w.OnRecv == nil {
+		return msg, err
+	}
+	return w.OnRecv(msg)
+}
+
+// StreamChain 流拦截器链，和 Chain 一一对应
+func StreamChain(interceptors ...StreamInterceptor) StreamInterceptor {
+	return func(stream ServerStream, handler StreamHandler) error {
+		return compileStream(interceptors, handler)(stream)
+	}
+}
+
+// compileStream 和 compile 一样，在注册时把流拦截器链编译好
+func compileStream(interceptors []StreamInterceptor, handler StreamHandler) StreamHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(stream ServerStream) error {
+			return interceptor(stream, next)
+		}
+	}
+	return handler
+}
+
+// streamRoute 一个已注册的流式方法
+type streamRoute struct {
+	fullMethod string
+	handler    StreamHandler
+	compiled   StreamHandler
+}
+
+// UseStream 添加流拦截器（类似 grpc.StreamInterceptor）
+func (s *MiniServer) UseStream(interceptors ...StreamInterceptor) {
+	s.update(func(t *routeTable) {
+		t.streamInterceptors = append(t.streamInterceptors, interceptors...)
+		for name, r := range t.streams {
+			t.streams[name] = &streamRoute{fullMethod: r.fullMethod, handler: r.handler, compiled: compileStream(t.streamInterceptors, r.handler)}
+		}
+	})
+}
+
+// HandleStream 注册流式处理器
+func (s *MiniServer) HandleStream(name string, handler StreamHandler) {
+	s.update(func(t *routeTable) {
+		t.streams[name] = &streamRoute{
+			fullMethod: middleware.FullMethod(miniServiceName, name),
+			handler:    handler,
+			compiled:   compileStream(t.streamInterceptors, handler),
+		}
+	})
+}
+
+// ClientStream 调用方看到的流（对应 grpc.ClientStream）
+type ClientStream interface {
+	Send(msg interface{}) error
+	// CloseSend 告诉服务端不会再发送消息，服务端 Recv 会收到 io.EOF
+	CloseSend() error
+	// Recv 服务端 handler 正常返回后得到 io.EOF，出错时得到它返回的错误
+	Recv() (interface{}, error)
+}
+
+// OpenStream 打开一个流，handler 在单独的 goroutine 里运行
+func (s *MiniServer) OpenStream(ctx context.Context, method string) (ClientStream, error) {
+	r, ok := s.table.Load().streams[method]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "流式方法不存在: %s", method)
+	}
+
+	ctx, cancel := context.WithCancel(middleware.WithMethod(ctx, r.fullMethod))
+	p := &pipe{
+		ctx:        ctx,
+		toServer:   make(chan interface{}),
+		sendClosed: make(chan struct{}),
+		toClient:   make(chan interface{}),
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer cancel() // 和 gRPC 一样，handler 返回后流的 context 就结束了
+		p.err = r.compiled(&pipeServerStream{p})
+		close(p.done)
+	}()
+	return &pipeClientStream{p}, nil
+}
+
+// pipe 进程内的双向流，两个方向都是无缓冲 channel：
+// handler 每次 Send 都要等调用方 Recv，所以 handler 返回前发出的消息一定已经被读走。
+// toServer 从不关闭：CloseSend 关闭的是 sendClosed，之后的 Send 返回错误而不是向已关闭的 channel 写入导致 panic
+type pipe struct {
+	ctx        context.Context
+	toServer   chan interface{}
+	sendClosed chan struct{} // 调用方已 CloseSend
+	toClient   chan interface{}
+	done       chan struct{} // handler 已返回
+	err        error         // handler 的返回值，done 关闭后才能读
+	closeOnce  sync.Once
+}
+
+type pipeServerStream struct{ p *pipe }
+
+func (s *pipeServerStream) Context() context.Context { return s.p.ctx }
+
+func (s *pipeServerStream) Send(msg interface{}) error {
+	select {
+	case s.p.toClient <- msg:
+		return nil
+	case <-s.p.ctx.Done():
+		return status.FromContextError(s.p.ctx.Err()).Err()
+	}
+}
+
+func (s *pipeServerStream) Recv() (interface{}, error) {
+	select {
+	case msg := <-s.p.toServer:
+		return msg, nil
+	case <-s.p.sendClosed:
+		return nil, io.EOF
+	case <-s.p.ctx.Done():
+		return nil, status.FromContextError(s.p.ctx.Err()).Err()
+	}
+}
+
+type pipeClientStream struct{ p *pipe }
+
+func (c *pipeClientStream) Send(msg interface{}) error {
+	select {
+	case <-c.p.sendClosed:
+		return status.Error(codes.FailedPrecondition, "send after CloseSend")
+	default:
+	}
+	select {
+	case c.p.toServer <- msg:
+		return nil
+	case <-c.p.sendClosed:
+		return status.Error(codes.FailedPrecondition, "send after CloseSend")
+	case <-c.p.done:
+		// 和 grpc 一样：流已经结束时 Send 返回 io.EOF，真正的错误从 Recv 拿
+		return io.EOF
+	}
+}
+
+func (c *pipeClientStream) CloseSend() error {
+	c.p.closeOnce.Do(func() { close(c.p.sendClosed) })
+	return nil
+}
+
+func (c *pipeClientStream) Recv() (interface{}, error) {
+	select {
+	case msg := <-c.p.toClient:
+		return msg, nil
+	case <-c.p.done:
+		if c.p.err != nil {
+			return nil, c.p.err
+		}
+		return nil, io.EOF
+	}
+}
+
+// ==================== 流拦截器示例 ====================
+
+// StreamLoggingInterceptor 打印流的打开和关闭，用来观察拦截器的执行顺序
+func StreamLoggingInterceptor(name string) StreamInterceptor {
+	return func(stream ServerStream, handler StreamHandler) error {
+		fmt.Printf("🌊 [%s] 打开流 %s\n", name, middleware.MethodFromContext(stream.Context()))
+		err := handler(stream)
+		fmt.Printf("🌊 [%s] 关闭流，错误: %v\n", name, err)
+		return err
+	}
+}
+
+// MessageCounterInterceptor 包装流，统计每个方向的消息数
+func MessageCounterInterceptor() StreamInterceptor {
+	return func(stream ServerStream, handler StreamHandler) error {
+		var sent, received int
+		wrapped := &WrappedStream{
+			ServerStream: stream,
+			OnSend: func(msg interface{}) (interface{}, error) {
+				sent++
+				return msg, nil
+			},
+			OnRecv: func(msg interface{}) (interface{}, error) {
+				received++
+				return msg, nil
+			},
+		}
+		err := handler(wrapped)
+		fmt.Printf("📊 [Counter] 收到 %d 条，发送 %d 条\n", received, sent)
+		return err
+	}
+}
+
+// Chat 业务逻辑 - 回声聊天，客户端 CloseSend 后结束
+func Chat(stream ServerStream) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(fmt.Sprintf("回声: %v", msg)); err != nil {
+			return err
+		}
+	}
+}
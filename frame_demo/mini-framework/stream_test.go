@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingInterceptor 记录打开、关闭流的顺序
+func recordingInterceptor(name string, events *[]string, mu *sync.Mutex) StreamInterceptor {
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		*events = append(*events, event)
+	}
+	return func(stream ServerStream, handler StreamHandler) error {
+		record(name + " 打开")
+		err := handler(stream)
+		record(name + " 关闭")
+		return err
+	}
+}
+
+// chat 发送 msgs，CloseSend 后读到流结束，返回收到的消息和结束时的错误
+func chat(t *testing.T, server *MiniServer, method string, msgs ...interface{}) ([]interface{}, error) {
+	t.Helper()
+	stream, err := server.OpenStream(context.Background(), method)
+	if err != nil {
+		t.Fatalf("OpenStream(%s): %v", method, err)
+	}
+	var replies []interface{}
+	for _, msg := range msgs {
+		if err := stream.Send(msg); err != nil {
+			t.Fatalf("Send(%v): %v", msg, err)
+		}
+		reply, err := stream.Recv()
+		if err != nil {
+			return replies, err
+		}
+		replies = append(replies, reply)
+	}
+	stream.CloseSend()
+	_, err = stream.Recv()
+	return replies, err
+}
+
+func TestStreamInterceptorOrder(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	server := NewMiniServer()
+	server.UseStream(recordingInterceptor("外层", &events, &mu))
+	server.HandleStream("Chat", func(stream ServerStream) error {
+		mu.Lock()
+		events = append(events, "handler")
+		mu.Unlock()
+		return Chat(stream)
+	})
+	// 注册之后追加的拦截器也要生效，而且排在里层
+	server.UseStream(recordingInterceptor("内层", &events, &mu))
+
+	if _, err := chat(t, server, "Chat", "你好"); err != io.EOF {
+		t.Fatalf("流结束时得到 %v，期望 io.EOF", err)
+	}
+	want := []string{"外层 打开", "内层 打开", "handler", "内层 关闭", "外层 关闭"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("执行顺序 = %v，期望 %v", events, want)
+	}
+}
+
+func TestStreamChainMatchesRegisteredOrder(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	chain := StreamChain(recordingInterceptor("a", &events, &mu), recordingInterceptor("b", &events, &mu))
+	err := chain(nil, func(ServerStream) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a 打开", "b 打开", "b 关闭", "a 关闭"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("执行顺序 = %v，期望 %v", events, want)
+	}
+}
+
+func TestWrappedStreamRewritesMessages(t *testing.T) {
+	server := NewMiniServer()
+	server.UseStream(func(stream ServerStream, handler StreamHandler) error {
+		return handler(&WrappedStream{
+			ServerStream: stream,
+			OnRecv: func(msg interface{}) (interface{}, error) {
+				return strings.ToUpper(msg.(string)), nil
+			},
+			OnSend: func(msg interface{}) (interface{}, error) {
+				return fmt.Sprintf("[%v]", msg), nil
+			},
+		})
+	})
+	server.HandleStream("Chat", Chat)
+
+	replies, err := chat(t, server, "Chat", "hi", "bye")
+	if err != io.EOF {
+		t.Fatalf("流结束时得到 %v，期望 io.EOF", err)
+	}
+	want := []interface{}{"[回声: HI]", "[回声: BYE]"}
+	if !reflect.DeepEqual(replies, want) {
+		t.Errorf("收到 %v，期望 %v", replies, want)
+	}
+}
+
+func TestWrappedStreamErrors(t *testing.T) {
+	server := NewMiniServer()
+	server.UseStream(func(stream ServerStream, handler StreamHandler) error {
+		return handler(&WrappedStream{
+			ServerStream: stream,
+			OnRecv: func(msg interface{}) (interface{}, error) {
+				if msg == "bad" {
+					return nil, status.Error(codes.InvalidArgument, "非法消息")
+				}
+				return msg, nil
+			},
+		})
+	})
+	server.HandleStream("Chat", Chat)
+
+	_, err := chat(t, server, "Chat", "ok", "bad")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("得到 %v，期望 InvalidArgument", err)
+	}
+}
+
+func TestWrappedStreamContext(t *testing.T) {
+	type key struct{}
+	server := NewMiniServer()
+	server.UseStream(func(stream ServerStream, handler StreamHandler) error {
+		ctx := context.WithValue(stream.Context(), key{}, "tenant-a")
+		return handler(&WrappedStream{ServerStream: stream, Ctx: ctx})
+	})
+	server.HandleStream("Tenant", func(stream ServerStream) error {
+		return stream.Send(stream.Context().Value(key{}))
+	})
+
+	stream, err := server.OpenStream(context.Background(), "Tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || msg != "tenant-a" {
+		t.Errorf("Recv() = %v, %v，期望 tenant-a", msg, err)
+	}
+}
+
+func TestSendAfterCloseSend(t *testing.T) {
+	server := NewMiniServer()
+	server.HandleStream("Chat", Chat)
+	stream, err := server.OpenStream(context.Background(), "Chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	stream.CloseSend() // 重复调用不应该 panic
+	if err := stream.Send("late"); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("CloseSend 之后 Send 得到 %v，期望 FailedPrecondition", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Recv() = %v，期望 io.EOF", err)
+	}
+}
+
+func TestOpenStreamNotFound(t *testing.T) {
+	if _, err := NewMiniServer().OpenStream(context.Background(), "Missing"); status.Code(err) != codes.NotFound {
+		t.Errorf("得到 %v，期望 NotFound", err)
+	}
+}
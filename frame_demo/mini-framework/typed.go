@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 类型安全的处理函数 ====================
+// Handler 的参数和返回值都是 interface{}，业务代码只能到处做类型断言。
+// HandleTyped 让业务函数直接写成 func(ctx, *HelloRequest) (*HelloReply, error)，
+// 类型转换只在框架入口做一次，拦截器链仍然按 interface{} 工作，原有中间件不用改。
+// Go 的方法不能带类型参数，所以这里是普通的泛型函数。
+
+// TypedHandler 带具体类型的业务处理函数
+type TypedHandler[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// HandleTyped 注册一个类型安全的处理函数
+// 请求类型不匹配、或者是 nil 指针时返回 codes.InvalidArgument，不会进入业务代码
+func HandleTyped[Req, Resp any](r Router, name string, handler TypedHandler[Req, Resp], opts ...RouteOption) {
+	r.Handle(name, func(ctx context.Context, req interface{}) (interface{}, error) {
+		typed, ok := req.(Req)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "方法 %s 需要 %s 类型的请求，收到 %T", name, typeName[Req](), req)
+		}
+		// (*HelloRequest)(nil) 也能通过上面的类型断言，HTTP 请求体是 null 时解码出来的就是它
+		if v := reflect.ValueOf(req); v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, status.Errorf(codes.InvalidArgument, "方法 %s 的请求不能为空", name)
+		}
+		return handler(ctx, typed)
+	}, append([]RouteOption{withDecoder(decodeJSON[Req])}, opts...)...)
+}
+
+// withDecoder 告诉 HTTP 传输层该把 JSON 解码成什么类型
+func withDecoder(decode func(data []byte) (interface{}, error)) RouteOption {
+	return func(r *route) {
+		r.decode = decode
+	}
+}
+
+// decodeJSON 把 JSON 解码成 Req，Req 是指针类型时 json 会自动分配
+func decodeJSON[Req any](data []byte) (interface{}, error) {
+	var req Req
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// CallTyped 调用方也拿到具体类型的响应
+// 拦截器可能替换响应，所以这里同样要检查类型
+func CallTyped[Req, Resp any](ctx context.Context, s *MiniServer, method string, req Req) (Resp, error) {
+	var zero Resp
+	resp, err := s.Call(ctx, method, req)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := resp.(Resp)
+	if !ok {
+		return zero, status.Errorf(codes.Internal, "方法 %s 应返回 %s 类型的响应，实际为 %T", method, typeName[Resp](), resp)
+	}
+	return typed, nil
+}
+
+// typeName 返回类型参数的名字（T 是接口类型时 %T 打印不出来，所以借助指针）
+func typeName[T any]() string {
+	return fmt.Sprintf("%T", (*T)(nil))[1:]
+}
+
+// ==================== 类型安全的业务代码 ====================
+
+type HelloRequest struct {
+	Name string `json:"name"`
+}
+
+type HelloReply struct {
+	Message string `json:"message"`
+}
+
+// SayHelloTyped 业务逻辑 - 不再需要类型断言
+func SayHelloTyped(ctx context.Context, req *HelloRequest) (*HelloReply, error) {
+	fmt.Println("💼 [Business] 执行类型安全的业务逻辑...")
+	return &HelloReply{Message: "Hello, " + req.Name + "!"}, nil
+}
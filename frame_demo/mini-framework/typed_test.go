@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTypedServer() *MiniServer {
+	server := NewMiniServer()
+	HandleTyped(server, "SayHelloTyped", func(ctx context.Context, req *HelloRequest) (*HelloReply, error) {
+		return &HelloReply{Message: "Hello, " + req.Name + "!"}, nil
+	})
+	return server
+}
+
+func TestHandleTypedRejectsBadRequests(t *testing.T) {
+	server := newTypedServer()
+	tests := []struct {
+		name string
+		req  interface{}
+	}{
+		{"类型不对", map[string]string{"name": "张三"}},
+		{"nil 接口", nil},
+		{"nil 指针", (*HelloRequest)(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := server.Call(context.Background(), "SayHelloTyped", tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("得到 %v，期望 InvalidArgument", err)
+			}
+		})
+	}
+
+	reply, err := CallTyped[*HelloRequest, *HelloReply](context.Background(), server, "SayHelloTyped", &HelloRequest{Name: "张三"})
+	if err != nil || reply.Message != "Hello, 张三!" {
+		t.Errorf("CallTyped() = %+v, %v", reply, err)
+	}
+}
+
+func TestHTTPTransportNullBody(t *testing.T) {
+	httpServer := httptest.NewServer(NewHTTPTransport(newTypedServer()))
+	defer httpServer.Close()
+
+	for body, want := range map[string]int{
+		`null`:          http.StatusBadRequest,
+		`{"name":"李四"}`: http.StatusOK,
+	} {
+		resp, err := http.Post(httpServer.URL+"/SayHelloTyped", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("POST %s -> %d，期望 %d", body, resp.StatusCode, want)
+		}
+	}
+}
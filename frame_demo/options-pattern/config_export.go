@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"frame_demo/redact"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 配置导出：打码的 JSON/YAML + JSON Schema ====================
+// 所有导出都按结构体标签遍历配置，新增字段只需要加标签，不用改打印代码：
+//   - 键名和配置文件一致（yaml 标签），导出结果可以直接当配置文件用
+//   - secret:"true" 的字段打码；Duration 输出成 "1h0m0s"；yaml 标签带 omitempty 的零值字段不输出
+//   - interceptor_config 这类 map 逐项遍历；配置块没有类型信息，键名里带 secret/password/token 等词的值同样打码
+//   - map 的键是排序的，两个实例的导出结果可以直接 diff：
+//
+//	diff <(ssh a ./server --print-config --print-format json) <(ssh b ./server --print-config --print-format json)
+//
+// ConfigSchema 生成 JSON Schema，编辑器可以用它给配置文件做补全和校验。
+
+// configKey 字段在配置文件里的键名
+func configKey(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// exportedFields 可以导出的字段（公开、没有被 yaml:"-" 排除）
+func exportedFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("yaml") != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// omitField yaml 标签带 omitempty 且值为零值时跳过（比如没调过的连接池参数）
+func omitField(field reflect.StructField, v reflect.Value) bool {
+	for _, option := range strings.Split(field.Tag.Get("yaml"), ",")[1:] {
+		if option == "omitempty" {
+			return v.IsZero()
+		}
+	}
+	return false
+}
+
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true" || field.Type == secretType
+}
+
+// secretKeyWords map 里的值没有类型和标签，只能按键名判断是不是密钥
+var secretKeyWords = []string{"secret", "password", "token", "credential", "private_key", "api_key"}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range secretKeyWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportConfig 把配置转成只含基本类型的 map，密钥已打码
+func ExportConfig(config ServerConfig) map[string]interface{} {
+	return exportValue(reflect.ValueOf(config), false).(map[string]interface{})
+}
+
+func exportValue(v reflect.Value, secret bool) interface{} {
+	if (secret || v.Type() == secretType) && v.Kind() == reflect.String {
+		return redact.Mask(v.String())
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return exportValue(v.Elem(), secret)
+	case reflect.Struct:
+		out := map[string]interface{}{}
+		for _, field := range exportedFields(v.Type()) {
+			fv, _ := v.FieldByIndexErr(field.Index)
+			if omitField(field, fv) {
+				continue
+			}
+			out[configKey(field)] = exportValue(fv, isSecretField(field))
+		}
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return exportValue(v.Elem(), secret)
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			key := fmt.Sprint(iter.Key().Interface())
+			out[key] = exportValue(iter.Value(), secret || isSecretKey(key))
+		}
+		return out
+	case reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = exportValue(v.Index(i), secret)
+		}
+		return items
+	case reflect.String:
+		return v.String() // 去掉 Secret 这类具名类型，编码器不会调用它们的方法
+	default:
+		return v.Interface()
+	}
+}
+
+// WriteConfig 以 yaml 或 json 格式输出打码后的配置
+func WriteConfig(w io.Writer, config ServerConfig, format string) error {
+	exported := ExportConfig(config)
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(exported)
+	case "yaml", "":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(exported); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("不支持的输出格式 %q（可选 yaml/json）", format)
+	}
+}
+
+// DumpConfig 输出服务器当前的配置（密钥轮换后也是最新的）
+func (s *Server) DumpConfig(w io.Writer, format string) error {
+	return WriteConfig(w, s.Config(), format)
+}
+
+// printFields PrintConfig 用：按 desc 标签逐行打印，嵌套结构体展开，空指针显示“未启用”
+func printFields(v reflect.Value, indent string) {
+	for _, field := range exportedFields(v.Type()) {
+		label := field.Tag.Get("desc")
+		if label == "" {
+			label = configKey(field)
+		}
+		fv, _ := v.FieldByIndexErr(field.Index)
+		switch {
+		case omitField(field, fv):
+		case field.Type.Kind() == reflect.Pointer && fv.IsNil():
+			fmt.Printf("%s%s: 未启用\n", indent, label)
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			printFields(fv.Elem(), indent)
+		case isSecretField(field):
+			fmt.Printf("%s%s: %s\n", indent, label, redact.Mask(fv.String()))
+		default:
+			fmt.Printf("%s%s: %v\n", indent, label, fv.Interface())
+		}
+	}
+}
+
+// ==================== JSON Schema ====================
+
+// durationPattern time.ParseDuration 接受的格式
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// ConfigSchema 从 ServerConfig 的类型和标签生成 JSON Schema（draft 2020-12），默认值取自 DefaultConfig
+func ConfigSchema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(ServerConfig{}), reflect.ValueOf(DefaultConfig()))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "ServerConfig"
+	return schema
+}
+
+// WriteConfigSchema 以 JSON 输出 Schema
+func WriteConfigSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ConfigSchema())
+}
+
+// schemaFor 生成一个类型的 schema；defaults 是该位置的默认值，无效值表示没有默认值
+func schemaFor(t reflect.Type, defaults reflect.Value) map[string]interface{} {
+	if t == durationType {
+		return map[string]interface{}{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		var elem reflect.Value
+		if defaults.IsValid() && !defaults.IsNil() {
+			elem = defaults.Elem()
+		}
+		schema := schemaFor(t.Elem(), elem)
+		schema["type"] = []string{schema["type"].(string), "null"}
+		return schema
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for _, field := range exportedFields(t) {
+			var fieldDefault reflect.Value
+			if defaults.IsValid() {
+				fieldDefault, _ = defaults.FieldByIndexErr(field.Index)
+			}
+			properties[configKey(field)] = fieldSchema(field, fieldDefault)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false, // 和加载器一样，未知的键视为错误
+		}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), reflect.Value{})}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), reflect.Value{})}
+	case reflect.Interface:
+		return map[string]interface{}{} // 任意值
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// fieldSchema 在类型 schema 上加上标签里的说明、可选值和默认值
+func fieldSchema(field reflect.StructField, defaults reflect.Value) map[string]interface{} {
+	schema := schemaFor(field.Type, defaults)
+	if desc := field.Tag.Get("desc"); desc != "" {
+		schema["description"] = desc
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		schema["enum"] = strings.Split(enum, ",")
+	}
+	if isSecretField(field) {
+		// 导出时打码，Schema 里标成只写；值可以是 env:/file:/keystore: 引用
+		schema["writeOnly"] = true
+		return schema
+	}
+	if defaults.IsValid() && !defaults.IsZero() && field.Type.Kind() != reflect.Pointer {
+		schema["default"] = exportValue(defaults, false)
+	}
+	return schema
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exportTestConfig 带密钥、Duration、配置块的配置
+func exportTestConfig() ServerConfig {
+	config := DefaultConfig()
+	config.JWTSecret = "super-secret-value"
+	config.AccessTTL = time.Hour
+	config.Redis = &RedisConfig{Address: "localhost:6379", Password: "redis-password", ReadTimeout: 500 * time.Millisecond}
+	config.InterceptorConfig = map[string]InterceptorSettings{
+		"rate-limit": {"rate": 50, "burst": 100},
+		"webhook": {
+			"url":       "https://hooks.example.com",
+			"api_token": "token-123456",
+			"upstream":  map[string]interface{}{"Password": "nested-password"},
+		},
+	}
+	return config
+}
+
+func TestWriteConfig(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteConfig(&buf, exportTestConfig(), format); err != nil {
+				t.Fatalf("WriteConfig: %v", err)
+			}
+			out := buf.String()
+			for _, plain := range []string{"super-secret-value", "redis-password", "token-123456", "nested-password"} {
+				if strings.Contains(out, plain) {
+					t.Errorf("输出里有明文 %q:\n%s", plain, out)
+				}
+			}
+
+			var got map[string]interface{}
+			unmarshal := yaml.Unmarshal
+			if format == "json" {
+				unmarshal = json.Unmarshal
+			}
+			if err := unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			redis := got["redis"].(map[string]interface{})
+			webhook := got["interceptor_config"].(map[string]interface{})["webhook"].(map[string]interface{})
+			checks := []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"jwt_secret 打码", got["jwt_secret"], "su****ue"},
+				{"redis.password 打码", redis["password"], "re****rd"},
+				{"配置块里按键名打码", webhook["api_token"], "to****56"},
+				{"嵌套的配置块也打码", webhook["upstream"].(map[string]interface{})["Password"], "ne****rd"},
+				{"其它值原样输出", webhook["url"], "https://hooks.example.com"},
+				{"Duration", got["access_ttl"], "1h0m0s"},
+				{"嵌套的 Duration", redis["read_timeout"], "500ms"},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("%s: %v，期望 %v", c.name, c.got, c.want)
+				}
+			}
+
+			// omitempty 的零值字段不输出，没有 omitempty 的照常输出
+			for _, key := range []string{"pool_size", "sentinel_addrs", "tls"} {
+				if _, ok := redis[key]; ok {
+					t.Errorf("redis.%s 是零值，不应该输出", key)
+				}
+			}
+			if _, ok := redis["db"]; !ok {
+				t.Error("redis.db 没有 omitempty，零值也应该输出")
+			}
+		})
+	}
+
+	if err := WriteConfig(&bytes.Buffer{}, DefaultConfig(), "toml"); err == nil || !strings.Contains(err.Error(), "不支持的输出格式") {
+		t.Errorf("错误 = %v，期望不支持的输出格式", err)
+	}
+}
+
+func TestExportConfig_OmitsEmpty(t *testing.T) {
+	exported := ExportConfig(DefaultConfig())
+	if _, ok := exported["interceptor_config"]; ok {
+		t.Error("interceptor_config 为空，不应该输出")
+	}
+	if exported["redis"] != nil {
+		t.Errorf("redis = %v，没有配置时应该是 null", exported["redis"])
+	}
+	if exported["jwt_secret"] != "未设置" {
+		t.Errorf("jwt_secret = %v，期望 未设置", exported["jwt_secret"])
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	// 经过一次 JSON 编解码，检查的是编辑器实际看到的内容
+	var buf bytes.Buffer
+	if err := WriteConfigSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	property := func(path ...string) map[string]interface{} {
+		props := properties
+		var p map[string]interface{}
+		for _, name := range path {
+			p = props[name].(map[string]interface{})
+			props, _ = p["properties"].(map[string]interface{})
+		}
+		return p
+	}
+
+	jwt := property("jwt_secret")
+	if jwt["writeOnly"] != true {
+		t.Errorf("jwt_secret 应该是 writeOnly: %v", jwt)
+	}
+	if _, ok := jwt["default"]; ok {
+		t.Errorf("密钥字段不应该有默认值: %v", jwt)
+	}
+	if password := property("redis", "password"); password["writeOnly"] != true {
+		t.Errorf("redis.password 应该是 writeOnly: %v", password)
+	}
+
+	level := property("log_level")
+	if want := []interface{}{"debug", "info", "warn", "error"}; !reflect.DeepEqual(level["enum"], want) {
+		t.Errorf("log_level enum = %v，期望 %v", level["enum"], want)
+	}
+	if level["default"] != "info" {
+		t.Errorf("log_level default = %v，期望来自 DefaultConfig 的 info", level["default"])
+	}
+
+	ttl := property("access_ttl")
+	if ttl["type"] != "string" || ttl["default"] != "24h0m0s" {
+		t.Errorf("access_ttl = %v，期望 string 类型、默认值 24h0m0s", ttl)
+	}
+	if redis := property("redis"); !reflect.DeepEqual(redis["type"], []interface{}{"object", "null"}) {
+		t.Errorf("redis type = %v，期望 [object null]", redis["type"])
+	}
+	if schema["additionalProperties"] != false {
+		t.Error("顶层应该禁止未知的键")
+	}
+}
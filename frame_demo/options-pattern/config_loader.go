@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 配置加载：文件 + 环境变量 + 命令行 ====================
+// 选项模式适合在代码里组合，但运维更习惯改配置文件和环境变量。
+// ConfigLoader 把这些来源合并成一个 ServerConfig，再转回 []Option，两种方式可以混用：
+//
+//	config, err := (&ConfigLoader{EnvPrefix: "APP_"}).Load(os.Args[1:])
+//	server, err := NewServer(append(config.Options(), WithInterceptors("metrics"))...)
+//
+// 优先级从低到高：默认值 < 配置文件（YAML/TOML）< profile 覆盖文件（见 profiles.go）< 环境变量 < 命令行参数
+// 命令行只覆盖显式传入的参数，没传的不会用 flag 的零值把文件里的配置冲掉。
+
+// ConfigLoader 配置加载器
+type ConfigLoader struct {
+	// EnvPrefix 环境变量前缀，如 "APP_"：APP_LOG_LEVEL -> LogLevel，APP_REDIS_ADDRESS -> Redis.Address
+	EnvPrefix string
+	// LookupEnv 读取环境变量，默认 os.LookupEnv（演示时可以换成 map）
+	LookupEnv func(key string) (string, bool)
+
+	// 以下字段由 Load 填写
+	Profile     string   // 选中的环境（--profile 或 <前缀>PROFILE）
+	Layers      []string // 实际生效的配置来源，按优先级从低到高
+	PrintConfig bool     // 命令行传了 --print-config
+	PrintFormat string   // --print-format：yaml（默认）或 json
+	PrintSchema bool     // 命令行传了 --print-schema
+}
+
+// commandLine 加载器自己的参数；配置字段对应的参数由 BindFlags 生成（见 flags.go）
+type commandLine struct {
+	configPath  string
+	profile     string
+	printConfig bool
+	printFormat string
+	printSchema bool
+}
+
+// Load 依次应用各个来源，返回合并后的配置
+func (l *ConfigLoader) Load(args []string) (*ServerConfig, error) {
+	// 1. 先解析命令行：配置文件路径本身也来自命令行
+	var cmd commandLine
+	fs := flag.NewFlagSet("options-pattern", flag.ContinueOnError)
+	fs.StringVar(&cmd.configPath, "config", "", "配置文件路径（.yaml/.yml/.toml）")
+	fs.StringVar(&cmd.profile, "profile", "", "环境（dev/staging/production），会叠加 config.<profile>.yaml")
+	fs.BoolVar(&cmd.printConfig, "print-config", false, "打印最终生效的配置（密钥打码）后退出")
+	fs.StringVar(&cmd.printFormat, "print-format", "yaml", "--print-config 的输出格式（yaml/json）")
+	fs.BoolVar(&cmd.printSchema, "print-schema", false, "打印配置文件的 JSON Schema 后退出")
+	flags := BindFlags(fs) // --jwt-secret、--jwt-secret-file、--log-level、--redis-addr、--listen ...
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	l.PrintConfig, l.PrintFormat, l.PrintSchema = cmd.printConfig, cmd.printFormat, cmd.printSchema
+	lookup := l.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	// 2. 默认值
+	config := DefaultConfig()
+	l.Layers = []string{"默认值"}
+
+	// 3. 配置文件：基础文件 + profile 覆盖文件（命令行优先于环境变量）
+	l.Profile = cmd.profile
+	if l.Profile == "" {
+		l.Profile, _ = lookup(l.EnvPrefix + "PROFILE")
+	}
+	var files []string
+	if cmd.configPath != "" {
+		files = append(files, cmd.configPath)
+	}
+	if l.Profile != "" {
+		if cmd.configPath == "" {
+			return nil, fmt.Errorf("选择了 profile %q，但没有用 --config 指定基础配置文件", l.Profile)
+		}
+		overlay := profileFile(cmd.configPath, l.Profile)
+		if _, err := os.Stat(overlay); err != nil {
+			return nil, fmt.Errorf("profile %q 没有对应的配置文件: %v", l.Profile, err)
+		}
+		files = append(files, overlay)
+	}
+	if len(files) > 0 {
+		if err := loadLayers(files, &config); err != nil {
+			return nil, err
+		}
+		l.Layers = append(l.Layers, files...)
+	}
+
+	// 4. 环境变量
+	envSet, err := applyEnv(reflect.ValueOf(&config).Elem(), l.EnvPrefix, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if envSet {
+		l.Layers = append(l.Layers, "环境变量 "+l.EnvPrefix+"*")
+	}
+
+	// 5. 命令行（只处理显式传入的参数）
+	flagSet, err := flags.Apply(&config)
+	if err != nil {
+		return nil, err
+	}
+	if flagSet {
+		l.Layers = append(l.Layers, "命令行")
+	}
+
+	return &config, nil
+}
+
+// LoadConfigFile 按扩展名读取 YAML 或 TOML 文件，覆盖 config 中出现的字段
+// 文件里有未知的键（多半是拼写错误）时返回错误
+func LoadConfigFile(path string, config *ServerConfig) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	case ".toml":
+		meta, err := toml.NewDecoder(f).Decode(config)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: 未知的配置项 %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("不支持的配置文件格式 %q（可选 .yaml/.yml/.toml）", ext)
+	}
+	return nil
+}
+
+// applyEnv 按 env 标签从环境变量填充结构体，嵌套结构体的变量名是 前缀+父标签_+子标签
+// 指针字段只有在至少一个子字段有环境变量时才会创建；返回是否设置了任何字段
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) (bool, error) {
+	var errs []error
+	set := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		key := prefix + name
+		fv := v.Field(i)
+
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			target := reflect.New(field.Type.Elem())
+			if !fv.IsNil() {
+				target.Elem().Set(fv.Elem())
+			}
+			nestedSet, err := applyEnv(target.Elem(), key+"_", lookup)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if nestedSet {
+				fv.Set(target)
+				set = true
+			}
+			continue
+		}
+
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setField(fv, raw); err != nil {
+			errs = append(errs, fmt.Errorf("环境变量 %s=%q: %v", key, raw, err))
+			continue
+		}
+		set = true
+	}
+	return set, errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField 把字符串解析成字段的类型
+func setField(fv reflect.Value, raw string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型 %s", fv.Type())
+		}
+		fv.Set(reflect.ValueOf(splitList(raw)))
+	default:
+		return fmt.Errorf("不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
+
+// splitList 解析逗号分隔的列表，忽略空白项
+func splitList(raw string) []string {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Options 把加载好的配置转成 Option 列表，可以和手写的 With... 选项拼在一起传给 NewServer
+// 注意：JWT 的 TTL 只有在设置了密钥时才会带上，日志级别只有在启用日志时才会带上
+func (c *ServerConfig) Options() []Option {
+	options := []Option{WithListenAddr(c.ListenAddr)}
+	if c.JWTSecret != "" {
+		options = append(options, WithJWTAuthAdvanced(c.JWTSecret.Value(), c.AccessTTL, c.RefreshTTL))
+	}
+	if c.EnableLogging {
+		options = append(options, WithLogging(c.LogLevel))
+	}
+	if len(c.Interceptors) > 0 {
+		options = append(options, WithInterceptors(c.Interceptors...))
+	}
+	for _, name := range sortedKeys(c.InterceptorConfig) {
+		options = append(options, WithInterceptorConfig(name, c.InterceptorConfig[name]))
+	}
+	if c.Redis != nil {
+		options = append(options, WithRedisConfig(*c.Redis))
+	}
+	return options
+}
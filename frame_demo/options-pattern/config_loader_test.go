@@ -0,0 +1,312 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile 在 dir 下写一个配置文件，返回路径
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigLoader_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", `
+log_level: warn
+access_ttl: 2h
+redis:
+  address: base:6379
+  db: 1
+`)
+	writeFile(t, dir, "config.prod.yaml", `
+log_level: error
+access_ttl: 1h
+redis:
+  db: 2
+`)
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		wantLevel  string
+		wantTTL    time.Duration
+		wantRedis  *RedisConfig
+		wantLayers int
+	}{
+		{
+			name:       "只有默认值",
+			wantLevel:  "info",
+			wantTTL:    24 * time.Hour,
+			wantLayers: 1,
+		},
+		{
+			name:       "配置文件覆盖默认值",
+			args:       []string{"--config", base},
+			wantLevel:  "warn",
+			wantTTL:    2 * time.Hour,
+			wantRedis:  &RedisConfig{Address: "base:6379", DB: 1},
+			wantLayers: 2,
+		},
+		{
+			name:       "profile 覆盖文件逐键合并",
+			args:       []string{"--config", base, "--profile", "prod"},
+			wantLevel:  "error",
+			wantTTL:    time.Hour,
+			wantRedis:  &RedisConfig{Address: "base:6379", DB: 2},
+			wantLayers: 3,
+		},
+		{
+			name:       "profile 也可以来自环境变量",
+			args:       []string{"--config", base},
+			env:        map[string]string{"APP_PROFILE": "prod"},
+			wantLevel:  "error",
+			wantTTL:    time.Hour,
+			wantRedis:  &RedisConfig{Address: "base:6379", DB: 2},
+			wantLayers: 3,
+		},
+		{
+			name:       "环境变量覆盖文件",
+			args:       []string{"--config", base, "--profile", "prod"},
+			env:        map[string]string{"APP_LOG_LEVEL": "debug", "APP_REDIS_DB": "3"},
+			wantLevel:  "debug",
+			wantTTL:    time.Hour,
+			wantRedis:  &RedisConfig{Address: "base:6379", DB: 3},
+			wantLayers: 4,
+		},
+		{
+			// --log-level info 和默认值相同，但显式传了就要生效；没传的 --access-ttl 不能把 1h 冲回 24h
+			name:       "命令行覆盖环境变量，只处理显式传入的参数",
+			args:       []string{"--config", base, "--profile", "prod", "--log-level", "info", "--redis-addr", "flag:6379"},
+			env:        map[string]string{"APP_LOG_LEVEL": "debug"},
+			wantLevel:  "info",
+			wantTTL:    time.Hour,
+			wantRedis:  &RedisConfig{Address: "flag:6379", DB: 2},
+			wantLayers: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &ConfigLoader{EnvPrefix: "APP_", LookupEnv: mapEnv(tt.env)}
+			config, err := loader.Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if config.LogLevel != tt.wantLevel {
+				t.Errorf("LogLevel = %q，期望 %q", config.LogLevel, tt.wantLevel)
+			}
+			if config.AccessTTL != tt.wantTTL {
+				t.Errorf("AccessTTL = %v，期望 %v", config.AccessTTL, tt.wantTTL)
+			}
+			if !reflect.DeepEqual(config.Redis, tt.wantRedis) {
+				t.Errorf("Redis = %+v，期望 %+v", config.Redis, tt.wantRedis)
+			}
+			if len(loader.Layers) != tt.wantLayers {
+				t.Errorf("Layers = %v，期望 %d 层", loader.Layers, tt.wantLayers)
+			}
+		})
+	}
+}
+
+func TestConfigLoader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", "log_level: warn\n")
+	typo := writeFile(t, dir, "typo.yaml", "log_levle: warn\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"profile 没有基础文件", []string{"--profile", "prod"}, nil, "没有用 --config 指定基础配置文件"},
+		{"profile 覆盖文件不存在", []string{"--config", base, "--profile", "prod"}, nil, "没有对应的配置文件"},
+		{"未知的键", []string{"--config", typo}, nil, "log_levle"},
+		{"不支持的格式", []string{"--config", writeFile(t, dir, "config.json", "{}")}, nil, "不支持的配置文件格式"},
+		{"环境变量格式错误", nil, map[string]string{"APP_ACCESS_TTL": "一小时"}, "APP_ACCESS_TTL"},
+		{"命令行格式错误", []string{"--redis-db", "two"}, nil, "redis-db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &ConfigLoader{EnvPrefix: "APP_", LookupEnv: mapEnv(tt.env)}
+			_, err := loader.Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeMaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst, src map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "对象逐键合并",
+			dst:  map[string]interface{}{"redis": map[string]interface{}{"address": "a:6379", "db": 0}},
+			src:  map[string]interface{}{"redis": map[string]interface{}{"db": 2}},
+			want: map[string]interface{}{"redis": map[string]interface{}{"address": "a:6379", "db": 2}},
+		},
+		{
+			name: "列表整体替换",
+			dst:  map[string]interface{}{"interceptors": []interface{}{"auth", "logging"}},
+			src:  map[string]interface{}{"interceptors": []interface{}{"metrics"}},
+			want: map[string]interface{}{"interceptors": []interface{}{"metrics"}},
+		},
+		{
+			name: "null 删除顶层键",
+			dst:  map[string]interface{}{"redis": map[string]interface{}{"address": "a:6379"}, "log_level": "info"},
+			src:  map[string]interface{}{"redis": nil},
+			want: map[string]interface{}{"log_level": "info"},
+		},
+		{
+			name: "null 删除嵌套键",
+			dst:  map[string]interface{}{"redis": map[string]interface{}{"address": "a:6379", "db": 1}},
+			src:  map[string]interface{}{"redis": map[string]interface{}{"address": nil, "mode": "sentinel"}},
+			want: map[string]interface{}{"redis": map[string]interface{}{"db": 1, "mode": "sentinel"}},
+		},
+		{
+			name: "标量替换对象",
+			dst:  map[string]interface{}{"redis": map[string]interface{}{"address": "a:6379"}},
+			src:  map[string]interface{}{"redis": "off"},
+			want: map[string]interface{}{"redis": "off"},
+		},
+		{
+			name: "删除不存在的键",
+			dst:  map[string]interface{}{"log_level": "info"},
+			src:  map[string]interface{}{"redis": nil},
+			want: map[string]interface{}{"log_level": "info"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeMaps(tt.dst, tt.src)
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("合并结果 = %v，期望 %v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		redis     *RedisConfig
+		env       map[string]string
+		wantSet   bool
+		wantRedis *RedisConfig
+		wantErr   string
+	}{
+		{
+			name:    "没有 Redis 变量时不创建 Redis",
+			env:     map[string]string{"APP_LOG_LEVEL": "debug"},
+			wantSet: true,
+		},
+		{
+			name:      "设置了子字段才创建 Redis",
+			env:       map[string]string{"APP_REDIS_DB": "3", "APP_REDIS_SENTINEL_ADDRS": "s0:26379, ,s1:26379"},
+			wantSet:   true,
+			wantRedis: &RedisConfig{DB: 3, SentinelAddrs: []string{"s0:26379", "s1:26379"}},
+		},
+		{
+			name:      "已有 Redis 时只覆盖设置了的字段",
+			redis:     &RedisConfig{Address: "file:6379", DB: 1},
+			env:       map[string]string{"APP_REDIS_READ_TIMEOUT": "500ms"},
+			wantSet:   true,
+			wantRedis: &RedisConfig{Address: "file:6379", DB: 1, ReadTimeout: 500 * time.Millisecond},
+		},
+		{
+			name:      "没有任何变量",
+			redis:     &RedisConfig{Address: "file:6379"},
+			wantRedis: &RedisConfig{Address: "file:6379"},
+		},
+		{
+			name:    "格式错误时报出变量名",
+			env:     map[string]string{"APP_REDIS_DB": "two"},
+			wantErr: "APP_REDIS_DB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Redis = tt.redis
+			set, err := applyEnv(reflect.ValueOf(&config).Elem(), "APP_", mapEnv(tt.env))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("错误 = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnv: %v", err)
+			}
+			if set != tt.wantSet {
+				t.Errorf("set = %v，期望 %v", set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(config.Redis, tt.wantRedis) {
+				t.Errorf("Redis = %+v，期望 %+v", config.Redis, tt.wantRedis)
+			}
+		})
+	}
+}
+
+func TestLoadLayers_MixedFormats(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.toml", `
+log_level = "info"
+interceptors = ["auth", "logging"]
+
+[redis]
+address = "localhost:6379"
+db = 1
+
+[interceptor_config.metrics]
+slow_threshold = "200ms"
+`)
+	overlay := writeFile(t, dir, "config.production.yaml", `
+interceptors: [auth, rate-limit]
+interceptor_config:
+  rate-limit: {rate: 500}
+redis:
+  mode: sentinel
+  address: null
+  master_name: mymaster
+`)
+
+	config := DefaultConfig()
+	if err := loadLayers([]string{base, overlay}, &config); err != nil {
+		t.Fatalf("loadLayers: %v", err)
+	}
+	if want := []string{"auth", "rate-limit"}; !reflect.DeepEqual(config.Interceptors, want) {
+		t.Errorf("Interceptors = %v，期望 %v", config.Interceptors, want)
+	}
+	if len(config.InterceptorConfig) != 2 {
+		t.Errorf("InterceptorConfig = %v，期望 metrics 和 rate-limit 两个配置块", config.InterceptorConfig)
+	}
+	want := &RedisConfig{Mode: "sentinel", MasterName: "mymaster", DB: 1}
+	if !reflect.DeepEqual(config.Redis, want) {
+		t.Errorf("Redis = %+v，期望 %+v", config.Redis, want)
+	}
+	if config.LogLevel != "info" {
+		t.Errorf("LogLevel = %q，覆盖层没写的键应该保留", config.LogLevel)
+	}
+
+	// 某一层有未知的键时，错误里带上是哪个文件
+	bad := writeFile(t, dir, "config.bad.yaml", "redis: {adress: x}\n")
+	config = DefaultConfig()
+	if err := loadLayers([]string{base, bad}, &config); err == nil || !strings.Contains(err.Error(), "config.bad.yaml") {
+		t.Errorf("错误 = %v，期望指出 config.bad.yaml", err)
+	}
+}
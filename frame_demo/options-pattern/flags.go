@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ==================== 命令行参数：从配置结构体自动生成 ====================
+// 每个服务都手写一遍 flag 解析，参数名、帮助文本、默认值很快就和配置对不上了。
+// BindFlags 按 ServerConfig 的标签生成参数，新增字段不用再改命令行代码：
+//   - 参数名取 yaml 键名，下划线换成连字符，嵌套结构体加上父级前缀：redis.pool_size -> --redis-pool-size
+//   - flag 标签可以改名（RedisConfig.Address 的 flag:"addr" -> --redis-addr），flag:"-" 表示不生成
+//   - 帮助文本来自 desc 和 enum，默认值来自 DefaultConfig
+//   - 密钥字段额外生成 --xxx-file，值会变成 file:<路径> 引用，密钥本身不会出现在进程列表里
+//   - 列表用逗号分隔，时长用 30s、5m 这样的格式；map（如 interceptor_config）只能写在配置文件里
+//
+//	fs := flag.NewFlagSet("server", flag.ExitOnError)
+//	flags := BindFlags(fs)
+//	fs.Parse(os.Args[1:])
+//	server, err := NewServer(append([]Option{WithInterceptors("auth")}, flags.Options()...)...)
+
+// FlagBinding 绑定到 FlagSet 上的配置参数
+type FlagBinding struct {
+	fs    *flag.FlagSet
+	flags map[string]*boundFlag
+}
+
+// boundFlag 一个参数对应的字段和原始值；值在生成 Option 时才写进配置，
+// 所以配置加载器可以先加载文件和环境变量，最后再应用命令行
+type boundFlag struct {
+	path  []int        // 从 ServerConfig 开始的字段下标，经过空指针时自动创建
+	typ   reflect.Type // 字段类型，Set 时用它检查格式
+	value string
+	file  bool // --xxx-file：值是文件路径
+}
+
+func (f *boundFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+// Set 解析时就检查格式，写错的时长、数字和 flag 包自己的错误一起报告
+func (f *boundFlag) Set(raw string) error {
+	if err := setField(reflect.New(f.typ).Elem(), raw); err != nil {
+		return err
+	}
+	f.value = raw
+	return nil
+}
+
+// IsBoolFlag 布尔参数可以只写 --enable-logging，不用写 =true
+func (f *boundFlag) IsBoolFlag() bool {
+	return f.typ.Kind() == reflect.Bool
+}
+
+// apply 把值写进 config 对应的字段
+func (f *boundFlag) apply(config *ServerConfig) error {
+	v := reflect.ValueOf(config).Elem()
+	for _, i := range f.path {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	raw := f.value
+	if f.file {
+		raw = "file:" + raw
+	}
+	return setField(v, raw)
+}
+
+// BindFlags 按 ServerConfig 的标签在 fs 上注册参数
+func BindFlags(fs *flag.FlagSet) *FlagBinding {
+	b := &FlagBinding{fs: fs, flags: map[string]*boundFlag{}}
+	b.bind(reflect.TypeOf(ServerConfig{}), reflect.ValueOf(DefaultConfig()), "", nil)
+	return b
+}
+
+// bind 注册 t 的字段；defaults 是该位置的默认值，无效值表示没有默认值
+func (b *FlagBinding) bind(t reflect.Type, defaults reflect.Value, prefix string, path []int) {
+	for _, field := range exportedFields(t) {
+		name := field.Tag.Get("flag")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ReplaceAll(configKey(field), "_", "-")
+		}
+		name = prefix + name
+		fieldPath := append(append([]int(nil), path...), field.Index...)
+		var fieldDefault reflect.Value
+		if defaults.IsValid() {
+			fieldDefault, _ = defaults.FieldByIndexErr(field.Index)
+		}
+
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			var elem reflect.Value
+			if fieldDefault.IsValid() && !fieldDefault.IsNil() {
+				elem = fieldDefault.Elem()
+			}
+			b.bind(field.Type.Elem(), elem, name+"-", fieldPath)
+			continue
+		}
+		if !flagSupported(field.Type) {
+			continue
+		}
+
+		usage := flagUsage(field)
+		bound := &boundFlag{path: fieldPath, typ: field.Type}
+		if !isSecretField(field) && fieldDefault.IsValid() && !fieldDefault.IsZero() {
+			bound.value = formatFlagValue(fieldDefault)
+		}
+		b.add(name, bound, usage)
+		if isSecretField(field) {
+			b.add(name+"-file", &boundFlag{path: fieldPath, typ: field.Type, file: true}, field.Tag.Get("desc")+"文件路径（相当于 file:<路径>）")
+		}
+	}
+}
+
+func (b *FlagBinding) add(name string, bound *boundFlag, usage string) {
+	b.fs.Var(bound, name, usage)
+	b.flags[name] = bound
+}
+
+// flagSupported setField 能解析的类型
+func flagSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+// flagUsage 帮助文本：desc + 可选值 + 格式提示
+func flagUsage(field reflect.StructField) string {
+	usage := field.Tag.Get("desc")
+	if usage == "" {
+		usage = configKey(field)
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		usage += "（可选 " + strings.ReplaceAll(enum, ",", "/") + "）"
+	}
+	switch {
+	case field.Type == durationType:
+		usage += "，如 30s、5m"
+	case field.Type.Kind() == reflect.Slice:
+		usage += "，逗号分隔"
+	case isSecretField(field):
+		usage += "，可以是 env:/file:/keystore: 引用"
+	}
+	return usage
+}
+
+// formatFlagValue 默认值的显示格式，和 setField 能解析的格式一致
+func formatFlagValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Options 命令行上显式传入的参数，每个参数一个 Option；没传的参数不会用默认值覆盖代码里的选项
+func (b *FlagBinding) Options() []Option {
+	var options []Option
+	b.fs.Visit(func(f *flag.Flag) {
+		bound, ok := b.flags[f.Name]
+		if !ok {
+			return // FlagSet 上其他的参数（如 --config）
+		}
+		name := f.Name
+		options = append(options, func(config *ServerConfig) error {
+			if err := bound.apply(config); err != nil {
+				return fmt.Errorf("--%s: %v", name, err)
+			}
+			return nil
+		})
+	})
+	return options
+}
+
+// Apply 把显式传入的参数写进 config，返回是否设置了任何参数
+func (b *FlagBinding) Apply(config *ServerConfig) (bool, error) {
+	options := b.Options()
+	var errs []error
+	for _, opt := range options {
+		if err := opt(config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(options) > 0, errors.Join(errs...)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFlagSet 测试用的 FlagSet，不往 stderr 打印用法
+func newFlagSet() (*flag.FlagSet, *FlagBinding) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs, BindFlags(fs)
+}
+
+func TestBindFlags_Names(t *testing.T) {
+	fs, _ := newFlagSet()
+	for _, name := range []string{
+		"listen", "jwt-secret", "jwt-secret-file", "access-ttl", "enable-logging", "log-level", "interceptors",
+		"redis-addr", "redis-password-file", "redis-pool-size", "redis-sentinel-addrs",
+	} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("缺少参数 --%s", name)
+		}
+	}
+	for _, name := range []string{
+		"redis-address",      // flag:"addr" 改了名
+		"interceptor-config", // map 只能写在配置文件里
+		"log-level-file",     // 只有密钥字段才有 -file
+	} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("不应该有参数 --%s", name)
+		}
+	}
+}
+
+func TestBindFlags_Usage(t *testing.T) {
+	fs, _ := newFlagSet()
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	usage := buf.String()
+
+	// 默认值来自 DefaultConfig
+	for _, want := range []string{
+		"(default 0.0.0.0:8080)",
+		"(default 24h0m0s)",
+		"日志级别（可选 debug/info/warn/error） (default info)",
+		"JWT密钥文件路径（相当于 file:<路径>）",
+	} {
+		if !strings.Contains(usage, want) {
+			t.Errorf("-h 输出缺少 %q:\n%s", want, usage)
+		}
+	}
+	// 密钥参数不显示默认值
+	jwt := fs.Lookup("jwt-secret")
+	if jwt.DefValue != "" || !strings.Contains(jwt.Usage, "可以是 env:/file:/keystore: 引用") {
+		t.Errorf("--jwt-secret DefValue = %q，Usage = %q", jwt.DefValue, jwt.Usage)
+	}
+}
+
+func TestFlagBinding_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantSet bool
+		check   func(config ServerConfig) bool
+	}{
+		{
+			name:  "没有参数时不修改配置",
+			check: func(c ServerConfig) bool { return reflect.DeepEqual(c, DefaultConfig()) },
+		},
+		{
+			name:    "--redis-addr 创建 Redis 配置",
+			args:    []string{"--redis-addr", "cache:6379", "--redis-pool-size", "20"},
+			wantSet: true,
+			check: func(c ServerConfig) bool {
+				return c.Redis != nil && c.Redis.Address == "cache:6379" && c.Redis.PoolSize == 20
+			},
+		},
+		{
+			name:    "--jwt-secret-file 变成 file: 引用",
+			args:    []string{"--jwt-secret-file", "/run/secrets/jwt"},
+			wantSet: true,
+			check:   func(c ServerConfig) bool { return c.JWTSecret == "file:/run/secrets/jwt" },
+		},
+		{
+			name:    "--listen、时长和列表",
+			args:    []string{"--listen", "127.0.0.1:9000", "--access-ttl", "1h", "--interceptors", "auth, logging"},
+			wantSet: true,
+			check: func(c ServerConfig) bool {
+				return c.ListenAddr == "127.0.0.1:9000" && c.AccessTTL == time.Hour && reflect.DeepEqual(c.Interceptors, []string{"auth", "logging"})
+			},
+		},
+		{
+			name:    "布尔参数可以不写值",
+			args:    []string{"--enable-logging"},
+			wantSet: true,
+			check:   func(c ServerConfig) bool { return c.EnableLogging },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, flags := newFlagSet()
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			config := DefaultConfig()
+			set, err := flags.Apply(&config)
+			if err != nil || set != tt.wantSet {
+				t.Fatalf("Apply = %v, %v，期望 %v", set, err, tt.wantSet)
+			}
+			if !tt.check(config) {
+				t.Errorf("配置不对: %+v", config)
+			}
+		})
+	}
+}
+
+func TestFlagBinding_InvalidDuration(t *testing.T) {
+	// 解析时就检查格式
+	fs, flags := newFlagSet()
+	if err := fs.Parse([]string{"--access-ttl", "一小时"}); err == nil || !strings.Contains(err.Error(), "access-ttl") {
+		t.Errorf("Parse 错误 = %v，期望指出 access-ttl", err)
+	}
+
+	// Apply 同样带上参数名报告错误（绕过 Set，直接放一个非法的值进去）
+	if err := fs.Parse([]string{"--access-ttl", "1h", "--refresh-ttl", "2h"}); err != nil {
+		t.Fatal(err)
+	}
+	flags.flags["access-ttl"].value = "一小时"
+	flags.flags["refresh-ttl"].value = "两小时"
+	config := DefaultConfig()
+	_, err := flags.Apply(&config)
+	if err == nil {
+		t.Fatal("Apply 应该失败")
+	}
+	for _, want := range []string{"--access-ttl", "--refresh-ttl"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误 = %v，缺少 %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 环境 Profile：基础配置 + 覆盖层 ====================
+// 与其在代码里写 if env == "production" { ... } else { ... }，不如把差异放进覆盖文件：
+//
+//	config.yaml             所有环境共用的基础配置
+//	config.production.yaml  只写生产环境不一样的部分
+//	config.dev.yaml         只写开发环境不一样的部分
+//
+// 用 --profile production 或环境变量 APP_PROFILE=production 选择，两层按下面的规则深度合并：
+//   - 对象（如 redis）逐个键合并，覆盖层没写的键保留基础配置的值
+//   - 标量和列表（如 interceptors）整体替换，不做拼接
+//   - 覆盖层里写 null 表示删除这个键，恢复成默认值（如 redis: null 关闭 Redis）
+//
+// --print-config 打印合并后最终生效的配置（密钥打码），排查“到底用的哪个值”时很有用。
+
+// profileFile 覆盖文件路径：config.yaml + production -> config.production.yaml
+func profileFile(base, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// loadLayers 按顺序读取配置文件，深度合并后解码到 config
+func loadLayers(files []string, config *ServerConfig) error {
+	merged := map[string]interface{}{}
+	for _, path := range files {
+		// 先单独解码一次，未知的键能报出具体是哪个文件
+		scratch := DefaultConfig()
+		if err := LoadConfigFile(path, &scratch); err != nil {
+			return err
+		}
+		layer, err := readConfigMap(path)
+		if err != nil {
+			return err
+		}
+		mergeMaps(merged, layer)
+	}
+
+	// 合并结果统一转成 YAML 再解码，TOML 和 YAML 的层可以混用
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("合并配置失败: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("合并配置失败: %v", err)
+	}
+	return nil
+}
+
+// readConfigMap 把配置文件读成通用的 map，用于合并
+func readConfigMap(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	layer := map[string]interface{}{}
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		err = toml.Unmarshal(data, &layer)
+	} else {
+		err = yaml.Unmarshal(data, &layer)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return layer, nil
+}
+
+// mergeMaps 把 src 深度合并进 dst：对象递归合并，其它值整体替换，nil 删除
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// WriteEffectiveConfig 输出最终生效的配置（密钥打码），开头注明各层来源
+func WriteEffectiveConfig(w io.Writer, config *ServerConfig, layers []string, format string) error {
+	if format != "json" { // JSON 不支持注释
+		fmt.Fprintf(w, "# 生效的配置（优先级从低到高: %s）\n", strings.Join(layers, " < "))
+	}
+	return WriteConfig(w, *config, format)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestProfileFile(t *testing.T) {
+	tests := []struct {
+		base, profile, want string
+	}{
+		{"config.yaml", "production", "config.production.yaml"},
+		{"/etc/app/config.toml", "dev", "/etc/app/config.dev.toml"},
+		{"conf.d/app.v2.yml", "staging", "conf.d/app.v2.staging.yml"}, // 只在最后一个扩展名前插入
+		{"config", "prod", "config.prod"},                             // 没有扩展名
+	}
+	for _, tt := range tests {
+		if got := profileFile(tt.base, tt.profile); got != tt.want {
+			t.Errorf("profileFile(%q, %q) = %q，期望 %q", tt.base, tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestWriteEffectiveConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", `
+jwt_secret: base-secret-value
+log_level: info
+interceptors: [auth, logging]
+interceptor_config:
+  metrics: {slow_threshold: 200ms}
+redis:
+  address: base:6379
+  db: 1
+`)
+	overlay := writeFile(t, dir, "config.production.yaml", `
+log_level: error
+interceptors: [auth, rate-limit]
+interceptor_config:
+  rate-limit: {rate: 500}
+redis:
+  address: null
+  mode: sentinel
+  master_name: mymaster
+  sentinel_addrs: [s0:26379]
+`)
+	loader := &ConfigLoader{
+		EnvPrefix: "APP_",
+		LookupEnv: mapEnv(map[string]string{"APP_PROFILE": "production", "APP_REDIS_DB": "3"}),
+	}
+	config, err := loader.Load([]string{"--config", base})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// 列表整体替换；interceptor_config、redis 逐键合并；null 删除 redis.address；环境变量最后覆盖 redis.db
+	want := fmt.Sprintf(`# 生效的配置（优先级从低到高: 默认值 < %s < %s < 环境变量 APP_*）
+access_ttl: 24h0m0s
+enable_logging: false
+interceptor_config:
+  metrics:
+    slow_threshold: 200ms
+  rate-limit:
+    rate: 500
+interceptors:
+  - auth
+  - rate-limit
+jwt_secret: ba****ue
+listen: 0.0.0.0:8080
+log_level: error
+redis:
+  db: 3
+  master_name: mymaster
+  mode: sentinel
+  password: 未设置
+  sentinel_addrs:
+    - s0:26379
+refresh_ttl: 168h0m0s
+`, base, overlay)
+	var buf bytes.Buffer
+	if err := WriteEffectiveConfig(&buf, config, loader.Layers, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("生效的配置 =\n%s\n期望\n%s", got, want)
+	}
+
+	// JSON 不支持注释，不输出来源那一行
+	buf.Reset()
+	if err := WriteEffectiveConfig(&buf, config, loader.Layers, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var exported map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("JSON 输出不合法: %v\n%s", err, buf.String())
+	}
+	if exported["log_level"] != "error" {
+		t.Errorf("log_level = %v，期望 error", exported["log_level"])
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==================== Redis 模块 ====================
+// WithRedis 只是把地址、密码存进配置，NewServer 看到 Redis 配置后才创建真正的客户端：
+//   - 支持 standalone / sentinel / cluster 三种部署，连接池、超时、TLS 都来自 RedisConfig
+//   - 后台定期 PING，结果计入 Server.Ready（/readyz 返回 503 时负载均衡器会把实例摘掉）
+//   - Stats 暴露连接池指标
+//   - Server.Close 等正在处理的请求结束后，停止健康检查并关闭连接池
+//
+// handler 拿客户端有两种方式：
+//
+//	rdb := RedisFromContext(ctx)   // 通过 Invoke 进来的请求，ctx 里已经注入了客户端
+//	repo := NewUserRepo(server.Redis().Client())  // 构造时依赖注入
+
+// defaultHealthCheckInterval RedisConfig.HealthCheckInterval 为零时的检查间隔
+const defaultHealthCheckInterval = 10 * time.Second
+
+// RedisModule Redis 连接池和它的健康检查
+type RedisModule struct {
+	client   redis.UniversalClient
+	interval time.Duration
+
+	health   atomic.Pointer[redisHealth] // 最近一次健康检查的结果
+	checks   atomic.Uint64
+	failures atomic.Uint64
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+type redisHealth struct {
+	err error
+	at  time.Time
+}
+
+// newRedisModule 创建连接池并启动健康检查（go-redis 按需建连，这里不会阻塞）
+// password 每次建连时调用，密钥轮换后新连接使用新密码
+func newRedisModule(config RedisConfig, password func() string) (*RedisModule, error) {
+	options := &redis.UniversalOptions{
+		DB:           config.DB,
+		PoolSize:     config.PoolSize,
+		MinIdleConns: config.MinIdleConns,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		CredentialsProvider: func() (string, string) {
+			return "", password()
+		},
+	}
+	if config.TLS {
+		tlsConfig, err := redisTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	// 按 Mode 显式选择客户端，不让 NewUniversalClient 根据地址个数去猜
+	var client redis.UniversalClient
+	switch redisMode(&config) {
+	case "sentinel":
+		// 每次建连都先问 Sentinel 主节点在哪，还会订阅 +switch-master，切换后旧主节点的连接会被关闭
+		options.MasterName = config.MasterName
+		options.Addrs = config.SentinelAddrs
+		options.SentinelPassword = config.SentinelPassword.Value()
+		client = redis.NewFailoverClient(options.Failover())
+	case "cluster":
+		// 从种子节点拉取槽位分布，收到 MOVED/ASK 时自动重定向并刷新
+		options.Addrs = config.ClusterAddrs
+		client = redis.NewClusterClient(options.Cluster())
+	default:
+		options.Addrs = []string{config.Address}
+		client = redis.NewClient(options.Simple())
+	}
+
+	interval := config.HealthCheckInterval
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	m := &RedisModule{
+		client:   client,
+		interval: interval,
+		stop:     stop,
+		done:     make(chan struct{}),
+	}
+	m.health.Store(&redisHealth{err: errors.New("尚未完成首次健康检查")})
+	go m.run(ctx)
+	return m, nil
+}
+
+// redisTLSConfig 最低 TLS 1.2；指定了 CA 证书时只信任它（自建 Redis 常用自签证书）
+// 不设置 ServerName：sentinel/cluster 会连很多节点，tls.DialWithDialer 会按每个节点的地址填上
+func redisTLSConfig(config RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCAFile == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(config.TLSCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Redis CA证书失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("Redis CA证书 %s 中没有有效的 PEM 证书", config.TLSCAFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// Client 底层的 go-redis 客户端
+func (m *RedisModule) Client() redis.UniversalClient {
+	return m.client
+}
+
+// run 立即检查一次，之后每 interval 检查一次
+func (m *RedisModule) run(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow 立即 PING 一次并更新健康状态，状态变化时打日志
+func (m *RedisModule) CheckNow(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.interval)
+	defer cancel()
+	err := m.client.Ping(ctx).Err()
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return err // 正在关闭，不算一次失败
+	}
+
+	m.checks.Add(1)
+	if err != nil {
+		m.failures.Add(1)
+	}
+	previous := m.health.Swap(&redisHealth{err: err, at: time.Now()})
+	switch {
+	case err != nil && (previous.at.IsZero() || previous.err == nil):
+		log.Printf("⚠️  [Redis] 健康检查失败: %v", err)
+	case err == nil && previous.err != nil && !previous.at.IsZero():
+		log.Printf("✅ [Redis] 连接已恢复")
+	}
+	return err
+}
+
+// Healthy 最近一次健康检查的结果，nil 表示健康
+func (m *RedisModule) Healthy() error {
+	return m.health.Load().err
+}
+
+// RedisStats 连接池和健康检查的指标
+type RedisStats struct {
+	TotalConns uint32 // 当前连接数
+	IdleConns  uint32 // 其中空闲的连接数
+	StaleConns uint32 // 因过期被关闭的连接数（累计）
+	Hits       uint32 // 从池里拿到空闲连接的次数
+	Misses     uint32 // 池里没有空闲连接、需要新建的次数
+	Timeouts   uint32 // 等待连接超时的次数（连接池太小）
+
+	HealthChecks        uint64
+	HealthCheckFailures uint64
+}
+
+func (s RedisStats) String() string {
+	return fmt.Sprintf("连接 %d（空闲 %d），命中 %d / 未命中 %d，等待超时 %d，健康检查 %d 次（失败 %d）",
+		s.TotalConns, s.IdleConns, s.Hits, s.Misses, s.Timeouts, s.HealthChecks, s.HealthCheckFailures)
+}
+
+// Stats 当前的连接池指标
+func (m *RedisModule) Stats() RedisStats {
+	pool := m.client.PoolStats()
+	return RedisStats{
+		TotalConns:          pool.TotalConns,
+		IdleConns:           pool.IdleConns,
+		StaleConns:          pool.StaleConns,
+		Hits:                pool.Hits,
+		Misses:              pool.Misses,
+		Timeouts:            pool.Timeouts,
+		HealthChecks:        m.checks.Load(),
+		HealthCheckFailures: m.failures.Load(),
+	}
+}
+
+// Close 停止健康检查并关闭连接池
+func (m *RedisModule) Close() error {
+	m.stop()
+	<-m.done
+	return m.client.Close()
+}
+
+// ==================== 通过 context 获取客户端 ====================
+
+type redisKey struct{}
+
+// ContextWithRedis 把客户端放进 ctx
+func ContextWithRedis(ctx context.Context, client redis.UniversalClient) context.Context {
+	return context.WithValue(ctx, redisKey{}, client)
+}
+
+// RedisFromContext 取出 Invoke 注入的客户端，没有配置 Redis 时返回 nil
+func RedisFromContext(ctx context.Context) redis.UniversalClient {
+	client, _ := ctx.Value(redisKey{}).(redis.UniversalClient)
+	return client
+}
+
+// ==================== 就绪检查和优雅关闭 ====================
+
+// Redis 没有配置 Redis 时返回 nil
+func (s *Server) Redis() *RedisModule {
+	return s.redis
+}
+
+// redisPassword 当前的 Redis 密码（RotateSecrets 之后会变）
+func (s *Server) redisPassword() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.config.Redis == nil {
+		return ""
+	}
+	return s.config.Redis.Password.Value()
+}
+
+// Ready 所有依赖都健康时返回 nil；正在关闭时也返回错误，让负载均衡器先把流量切走
+func (s *Server) Ready() error {
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+	if closed {
+		return errors.New("服务器正在关闭")
+	}
+	if s.redis != nil {
+		if err := s.redis.Healthy(); err != nil {
+			return fmt.Errorf("redis: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadyHandler 挂到 /readyz：就绪返回 200，否则 503 并带上原因
+func (s *Server) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Close 优雅关闭：不再接收新请求，等正在处理的请求结束（最多等到 ctx 过期），再关闭 Redis 连接池
+func (s *Server) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true // 之后 Invoke 直接返回 Unavailable，inflight 不会再增加
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var errs []error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("等待进行中的请求超时: %w", ctx.Err()))
+	}
+	if s.redis != nil {
+		if err := s.redis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭 Redis 连接池失败: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"frame_demo/validate"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	// 测试里会故意让 Redis 宕机，go-redis 的重连日志没有意义
+	redis.SetLogger(discardRedisLogger{})
+	os.Exit(m.Run())
+}
+
+// newTestServer NewServer 失败时直接结束测试，结束时关闭服务器
+func newTestServer(t *testing.T, options ...Option) *Server {
+	t.Helper()
+	server, err := NewServer(options...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { server.Close(context.Background()) })
+	return server
+}
+
+// readyz 模拟负载均衡器请求 /readyz
+func readyz(server *Server) int {
+	recorder := httptest.NewRecorder()
+	server.ReadyHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	return recorder.Code
+}
+
+// waitHealthy 连续拨号失败后 go-redis 会在后台每秒重试一次，恢复需要等一会儿
+func waitHealthy(t *testing.T, module *RedisModule) {
+	t.Helper()
+	ctx := context.Background()
+	for deadline := time.Now().Add(3 * time.Second); module.CheckNow(ctx) != nil; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Redis 没有恢复: %v", module.Healthy())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func countVisit(ctx context.Context, req interface{}) (interface{}, error) {
+	return RedisFromContext(ctx).Incr(ctx, "visits:"+req.(string)).Result()
+}
+
+func TestRedisModuleHealthAndStats(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("redis-pass")
+	server := newTestServer(t,
+		WithRedis(mr.Addr(), "redis-pass", 0),
+		WithRedisPool(4, 0),
+		WithRedisTimeouts(time.Second, 500*time.Millisecond, 500*time.Millisecond),
+	)
+	ctx := context.Background()
+	module := server.Redis()
+
+	if err := module.CheckNow(ctx); err != nil {
+		t.Fatalf("CheckNow: %v", err)
+	}
+	if err := server.Ready(); err != nil || readyz(server) != http.StatusOK {
+		t.Fatalf("Redis 正常时应该就绪，得到 %v", err)
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		visits, err := server.Invoke(ctx, "/demo.Counter/Visit", "alice", countVisit)
+		if err != nil || visits != want {
+			t.Fatalf("第 %d 次访问得到 %v, %v", want, visits, err)
+		}
+	}
+	stats := module.Stats()
+	if stats.TotalConns == 0 || stats.TotalConns > 4 {
+		t.Errorf("连接数 %d 应该在 1-4 之间（pool_size=4）", stats.TotalConns)
+	}
+	if stats.Hits == 0 {
+		t.Errorf("连续请求应该复用空闲连接: %v", stats)
+	}
+	if stats.HealthChecks == 0 || stats.HealthCheckFailures != 0 {
+		t.Errorf("健康检查计数不对: %v", stats)
+	}
+
+	// Redis 宕机：PING 失败，Ready 和 /readyz 跟着变成不可用
+	mr.Close()
+	if err := module.CheckNow(ctx); err == nil {
+		t.Fatal("Redis 宕机后 CheckNow 应该失败")
+	}
+	if err := server.Ready(); err == nil || !strings.HasPrefix(err.Error(), "redis: ") {
+		t.Errorf("Ready() = %v，期望 redis 错误", err)
+	}
+	if code := readyz(server); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d，期望 503", code)
+	}
+	if module.Stats().HealthCheckFailures == 0 {
+		t.Error("失败的健康检查没有计数")
+	}
+
+	// 恢复后自动回到就绪
+	mr.Restart()
+	waitHealthy(t, module)
+	if err := server.Ready(); err != nil {
+		t.Errorf("恢复后 Ready() = %v", err)
+	}
+}
+
+func TestRedisWrongPassword(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("redis-pass")
+	server := newTestServer(t, WithRedis(mr.Addr(), "wrong", 0))
+	if err := server.Redis().CheckNow(context.Background()); err == nil {
+		t.Fatal("密码错误时健康检查应该失败")
+	}
+	if server.Ready() == nil {
+		t.Error("密码错误时不应该就绪")
+	}
+}
+
+func TestRedisCloseWaitsForInflight(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := newTestServer(t, WithRedis(mr.Addr(), "", 0))
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	slow := make(chan error, 1)
+	go func() {
+		_, err := server.Invoke(ctx, "/demo.Counter/Visit", "bob", func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return countVisit(ctx, req) // 连接池还没关，照常可用
+		})
+		slow <- err
+	}()
+	<-started
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := server.Close(shutdownCtx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-slow:
+		if err != nil {
+			t.Errorf("关闭前进来的请求失败: %v", err)
+		}
+	default:
+		t.Error("Close 没有等进行中的请求结束")
+	}
+
+	if _, err := server.Invoke(ctx, "/demo.Counter/Visit", "alice", countVisit); status.Code(err) != codes.Unavailable {
+		t.Errorf("关闭后的请求得到 %v，期望 Unavailable", err)
+	}
+	if readyz(server) != http.StatusServiceUnavailable {
+		t.Error("关闭后 /readyz 应该返回 503")
+	}
+	if err := server.Close(ctx); err != nil {
+		t.Errorf("重复 Close 得到 %v", err)
+	}
+}
+
+func TestRedisCloseTimeout(t *testing.T) {
+	server := newTestServer(t)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go server.Invoke(context.Background(), "/demo.Slow", nil, func(context.Context, interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close 得到 %v，期望等待超时", err)
+	}
+}
+
+// sentinelStandIn 用 miniredis 扮演 Sentinel：回答 SENTINEL get-master-addr-by-name，切换时发布 +switch-master
+type sentinelStandIn struct {
+	*miniredis.Miniredis
+	name string
+
+	mu         sync.Mutex
+	masterAddr string
+}
+
+func newSentinelStandIn(t *testing.T, name, masterAddr string) *sentinelStandIn {
+	s := &sentinelStandIn{Miniredis: miniredis.RunT(t), name: name, masterAddr: masterAddr}
+	s.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		switch {
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == s.name:
+			s.mu.Lock()
+			host, port, _ := net.SplitHostPort(s.masterAddr)
+			s.mu.Unlock()
+			c.WriteStrings([]string{host, port})
+		case len(args) == 2 && strings.EqualFold(args[0], "sentinels"):
+			c.WriteLen(0) // 没有其他 Sentinel
+		default:
+			c.WriteError("ERR unsupported SENTINEL command")
+		}
+	})
+	return s
+}
+
+// failover 把主节点切到 to，和真正的 Sentinel 一样广播 "<name> <旧ip> <旧端口> <新ip> <新端口>"
+func (s *sentinelStandIn) failover(to string) {
+	s.mu.Lock()
+	from := s.masterAddr
+	s.masterAddr = to
+	s.mu.Unlock()
+	fromHost, fromPort, _ := net.SplitHostPort(from)
+	toHost, toPort, _ := net.SplitHostPort(to)
+	s.Publish("+switch-master", strings.Join([]string{s.name, fromHost, fromPort, toHost, toPort}, " "))
+}
+
+func TestRedisSentinelFailover(t *testing.T) {
+	ctx := context.Background()
+	master, replica := miniredis.RunT(t), miniredis.RunT(t)
+	sentinel := newSentinelStandIn(t, "mymaster", master.Addr())
+
+	server := newTestServer(t, WithRedisSentinel("mymaster", []string{sentinel.Addr()}, "", 0))
+	rdb := server.Redis().Client()
+	if err := rdb.Set(ctx, "leader", "node-1", 0).Err(); err != nil {
+		t.Fatalf("写入主节点失败: %v", err)
+	}
+	if got, _ := master.Get("leader"); got != "node-1" {
+		t.Fatalf("写入没有落在主节点上: %q", got)
+	}
+
+	// 主节点宕机，Sentinel 把从节点提升为主节点并广播 +switch-master
+	replica.Set("leader", "node-1") // 替身之间没有复制，手动同步一下
+	master.Close()
+	sentinel.failover(replica.Addr())
+	waitHealthy(t, server.Redis())
+
+	leader, err := rdb.Get(ctx, "leader").Result()
+	if err != nil || leader != "node-1" {
+		t.Errorf("切换后读到 %q, %v", leader, err)
+	}
+	if err := rdb.Incr(ctx, "failovers").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := replica.Get("failovers"); got != "1" {
+		t.Errorf("切换后的写入没有落在新主节点上: failovers=%q", got)
+	}
+}
+
+func TestRedisCluster(t *testing.T) {
+	// miniredis 对 CLUSTER SLOTS 回答“所有槽位都在我这里”，可以当单节点集群
+	node := miniredis.RunT(t)
+	server := newTestServer(t, WithRedisCluster([]string{node.Addr()}, ""))
+	ctx := context.Background()
+	if _, ok := server.Redis().Client().(*redis.ClusterClient); !ok {
+		t.Fatalf("cluster 模式应该创建 ClusterClient，得到 %T", server.Redis().Client())
+	}
+	if err := server.Redis().Client().Set(ctx, "{user:1}:name", "alice", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := node.Get("{user:1}:name"); got != "alice" {
+		t.Errorf("节点上读到 %q", got)
+	}
+	if err := server.Redis().CheckNow(ctx); err != nil {
+		t.Errorf("CheckNow: %v", err)
+	}
+}
+
+func TestRedisClientByMode(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := newTestServer(t, WithRedis(mr.Addr(), "", 0))
+	if _, ok := server.Redis().Client().(*redis.Client); !ok {
+		t.Errorf("standalone 模式应该创建 *redis.Client，得到 %T", server.Redis().Client())
+	}
+	if newTestServer(t).Redis() != nil {
+		t.Error("没有配置 Redis 时不应该创建模块")
+	}
+}
+
+func TestRedisTopologyValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RedisConfig
+		want   []string // 期望出现的错误，nil 表示配置合法
+	}{
+		{
+			name:   "standalone",
+			config: RedisConfig{Address: "localhost:6379"},
+		},
+		{
+			name:   "standalone 缺少端口",
+			config: RedisConfig{Address: "localhost"},
+			want:   []string{`Redis地址 "localhost" 不合法`},
+		},
+		{
+			name:   "standalone 混用 cluster 字段",
+			config: RedisConfig{Address: "localhost:6379", ClusterAddrs: []string{"redis-0:7000"}},
+			want:   []string{"Redis standalone 模式不使用 cluster_addrs"},
+		},
+		{
+			name:   "sentinel",
+			config: RedisConfig{Mode: "sentinel", MasterName: "mymaster", SentinelAddrs: []string{"s-0:26379", "s-1:26379"}},
+		},
+		{
+			name:   "sentinel 缺少主节点名和地址",
+			config: RedisConfig{Mode: "sentinel", Address: "localhost:6379"},
+			want: []string{
+				"Redis sentinel 模式必须设置主节点名（master_name）",
+				"Redis sentinel 模式至少需要一个 Sentinel 地址（sentinel_addrs）",
+				"Redis sentinel 模式不使用 address",
+			},
+		},
+		{
+			name:   "cluster",
+			config: RedisConfig{Mode: "cluster", ClusterAddrs: []string{"redis-0:7000", "redis-1:7000"}},
+		},
+		{
+			name:   "cluster 选库、混用 sentinel 字段",
+			config: RedisConfig{Mode: "cluster", ClusterAddrs: []string{"redis-0:7000", "redis-1"}, DB: 3, SentinelAddrs: []string{"s-0:26379"}},
+			want: []string{
+				`Cluster节点地址 "redis-1" 不合法`,
+				"Redis cluster 模式只有 0 号库，不能设置 db（收到 3）",
+				"Redis cluster 模式不使用 master_name/sentinel_addrs",
+			},
+		},
+		{
+			name:   "未知模式",
+			config: RedisConfig{Mode: "proxy"},
+			want:   []string{`未知的 Redis 模式 "proxy"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateRedisTopology(&tt.config)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("得到 %d 个问题 %q，期望 %d 个", len(problems), problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("问题 %d = %q，期望以 %q 开头", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisOptionsRequireRedis(t *testing.T) {
+	_, err := NewServer(WithRedisPool(4, 1), WithRedisTLS(""))
+	var configErr *validate.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Fatalf("没有配置 Redis 时 WithRedisPool/WithRedisTLS 应该各报一个错误，得到 %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"frame_demo/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 拦截器注册表 ====================
+// WithInterceptors("auth", "logging") 里的名字在 NewServer 时通过注册表变成真正的拦截器，
+// 所以配置文件可以按名字声明拦截器链，每个拦截器还可以有自己的配置块：
+//
+//	interceptors: [recovery, auth, rate-limit, logging]
+//	interceptor_config:
+//	  rate-limit: {rate: 50, burst: 100}
+//
+// 第三方包在 init 里注册自己的拦截器（和 database/sql 注册驱动一样）：
+//
+//	func init() { RegisterInterceptor("tenant", newTenantInterceptor) }
+
+// InterceptorSettings 单个拦截器的配置块
+type InterceptorSettings map[string]interface{}
+
+// Decode 把配置块解码到结构体（按 yaml 标签），未知的键视为错误；配置块为空时保留 target 的默认值
+func (s InterceptorSettings) Decode(target interface{}) error {
+	if len(s) == 0 {
+		return nil
+	}
+	if fields := settingKeys(reflect.TypeOf(target)); fields != nil {
+		var errs []error
+		for _, key := range sortedKeys(s) {
+			if !fields[key] {
+				errs = append(errs, fmt.Errorf("未知的配置项 %q", key))
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+	data, err := yaml.Marshal(map[string]interface{}(s))
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, target); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// "line 1: cannot unmarshal ..." 里的行号是临时生成的 yaml 的，去掉
+			var errs []error
+			for _, line := range typeErr.Errors {
+				_, msg, _ := strings.Cut(line, ": ")
+				errs = append(errs, errors.New(msg))
+			}
+			return errors.Join(errs...)
+		}
+		return err
+	}
+	return nil
+}
+
+// settingKeys target 指向结构体时返回它的 yaml 键名
+func settingKeys(t reflect.Type) map[string]bool {
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, field := range exportedFields(t.Elem()) {
+		keys[configKey(field)] = true
+	}
+	return keys
+}
+
+// InterceptorFactory 根据配置块创建拦截器
+// server 用来读取运行时配置（比如轮换后的 JWT 密钥），不要在创建时把它的值缓存下来
+type InterceptorFactory func(server *Server, settings InterceptorSettings) (middleware.Interceptor, error)
+
+var (
+	registryMu   sync.RWMutex
+	interceptors = map[string]InterceptorFactory{}
+)
+
+// RegisterInterceptor 注册拦截器；名字重复说明两个包抢了同一个名字，直接 panic
+func RegisterInterceptor(name string, factory InterceptorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" || factory == nil {
+		panic("RegisterInterceptor: 名字和 factory 都不能为空")
+	}
+	if _, dup := interceptors[name]; dup {
+		panic(fmt.Sprintf("RegisterInterceptor: 拦截器 %q 重复注册", name))
+	}
+	interceptors[name] = factory
+}
+
+func lookupInterceptor(name string) (InterceptorFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := interceptors[name]
+	return factory, ok
+}
+
+// RegisteredInterceptors 已注册的拦截器名字（排序）
+func RegisteredInterceptors() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(interceptors))
+	for name := range interceptors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedKeys 按名字排序，保证报错和生成的选项顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WithInterceptorConfig 设置某个拦截器的配置块
+func WithInterceptorConfig(name string, settings InterceptorSettings) Option {
+	return func(config *ServerConfig) error {
+		if config.InterceptorConfig == nil {
+			config.InterceptorConfig = map[string]InterceptorSettings{}
+		}
+		config.InterceptorConfig[name] = settings
+		return nil
+	}
+}
+
+// buildInterceptors 按 Interceptors 的顺序创建拦截器链，所有错误一起返回
+func (s *Server) buildInterceptors() ([]middleware.Interceptor, []string) {
+	var chain []middleware.Interceptor
+	var problems []string
+	for _, name := range s.config.Interceptors {
+		factory, ok := lookupInterceptor(name)
+		if !ok {
+			continue // 未知的名字在 Validate 中已经报告
+		}
+		interceptor, err := factory(s, s.config.InterceptorConfig[name])
+		if err != nil {
+			for _, line := range errorLines(err) {
+				problems = append(problems, fmt.Sprintf("拦截器 %s: %s", name, line))
+			}
+			continue
+		}
+		chain = append(chain, middleware.Traced(name, interceptor))
+	}
+	return chain, problems
+}
+
+// Invoke 让请求经过拦截器链再交给 handler（第一个拦截器在最外层）
+// 配置了 Redis 时 ctx 里会带上客户端，handler 用 RedisFromContext 取；Close 之后返回 Unavailable
+func (s *Server) Invoke(ctx context.Context, method string, req interface{}, handler middleware.Handler) (interface{}, error) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil, status.Errorf(codes.Unavailable, "服务器正在关闭")
+	}
+	s.inflight.Add(1)
+	s.mu.RUnlock()
+	defer s.inflight.Done()
+
+	if s.redis != nil {
+		ctx = ContextWithRedis(ctx, s.redis.Client())
+	}
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := s.interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler(middleware.WithMethod(ctx, method), req)
+}
+
+// ==================== 内置拦截器 ====================
+
+func init() {
+	RegisterInterceptor("recovery", func(*Server, InterceptorSettings) (middleware.Interceptor, error) {
+		return middleware.Recovery(), nil
+	})
+	RegisterInterceptor("logging", func(*Server, InterceptorSettings) (middleware.Interceptor, error) {
+		return middleware.Logging(), nil
+	})
+	RegisterInterceptor("auth", newAuthInterceptor)
+	RegisterInterceptor("rate-limit", newRateLimitInterceptor)
+	RegisterInterceptor("metrics", newMetricsInterceptor)
+}
+
+// auth: 校验 authorization: Bearer <JWTSecret>，每次请求都读取当前密钥（支持轮换）
+// 配置块：skip_methods 不需要认证的方法（支持通配符，如 /grpc.health.v1.Health/*）
+func newAuthInterceptor(server *Server, settings InterceptorSettings) (middleware.Interceptor, error) {
+	var options struct {
+		SkipMethods []string `yaml:"skip_methods"`
+	}
+	if err := settings.Decode(&options); err != nil {
+		return nil, err
+	}
+	if server.jwtSecret() == "" {
+		return nil, errors.New("启用了 auth 拦截器但没有设置JWT密钥")
+	}
+	skip := middleware.MethodMatcher(options.SkipMethods...)
+
+	return func(ctx context.Context, req interface{}, handler middleware.Handler) (interface{}, error) {
+		if skip(middleware.MethodFromContext(ctx)) {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get("authorization"); len(values) == 0 || values[0] != "Bearer "+server.jwtSecret().Value() {
+			return nil, status.Errorf(codes.Unauthenticated, "无效的Token")
+		}
+		return handler(ctx, req)
+	}, nil
+}
+
+// rate-limit: 配置块 rate（每秒请求数，默认 100）、burst（默认 200）
+func newRateLimitInterceptor(_ *Server, settings InterceptorSettings) (middleware.Interceptor, error) {
+	options := struct {
+		Rate  float64 `yaml:"rate"`
+		Burst int     `yaml:"burst"`
+	}{Rate: 100, Burst: 200}
+	if err := settings.Decode(&options); err != nil {
+		return nil, err
+	}
+	if options.Rate <= 0 || options.Burst < 1 {
+		return nil, fmt.Errorf("rate 必须大于 0、burst 至少为 1，收到 rate=%v burst=%d", options.Rate, options.Burst)
+	}
+	return middleware.RateLimit(options.Rate, options.Burst), nil
+}
+
+// metrics: 记录慢请求；配置块 slow_threshold（默认 500ms）
+func newMetricsInterceptor(_ *Server, settings InterceptorSettings) (middleware.Interceptor, error) {
+	options := struct {
+		SlowThreshold time.Duration `yaml:"slow_threshold"`
+	}{SlowThreshold: 500 * time.Millisecond}
+	if err := settings.Decode(&options); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, req interface{}, handler middleware.Handler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if elapsed := time.Since(start); elapsed >= options.SlowThreshold {
+			log.Printf("📊 [Metrics] 慢请求 %s 耗时 %v（阈值 %v）", middleware.MethodFromContext(ctx), elapsed, options.SlowThreshold)
+		}
+		return resp, err
+	}, nil
+}
+
+// unknownInterceptorProblem Validate 用：列出已注册的名字，方便发现拼写错误
+func unknownInterceptorProblem(name string) string {
+	return fmt.Sprintf("未知的拦截器 %q（已注册: %s）", name, strings.Join(RegisteredInterceptors(), "/"))
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"frame_demo/middleware"
+	"frame_demo/validate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// registerForTest 注册一个测试用的拦截器，测试结束时注销（-count 多次运行也不会重复注册）
+func registerForTest(t *testing.T, name string, factory InterceptorFactory) {
+	t.Helper()
+	RegisterInterceptor(name, factory)
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(interceptors, name)
+	})
+}
+
+// invoke 带着 metadata 调用一次，handler 原样返回请求
+func invoke(server *Server, method string, pairs ...string) (interface{}, error) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+	return server.Invoke(ctx, method, "req", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+}
+
+func TestRegisterInterceptor_Panics(t *testing.T) {
+	factory := func(*Server, InterceptorSettings) (middleware.Interceptor, error) { return middleware.Logging(), nil }
+	tests := []struct {
+		name      string
+		regName   string
+		factory   InterceptorFactory
+		wantPanic string
+	}{
+		{"名字重复", "auth", factory, `拦截器 "auth" 重复注册`},
+		{"名字为空", "", factory, "名字和 factory 都不能为空"},
+		{"factory 为空", "nil-factory", nil, "名字和 factory 都不能为空"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), tt.wantPanic) {
+					t.Errorf("panic = %v，期望包含 %q", r, tt.wantPanic)
+				}
+			}()
+			RegisterInterceptor(tt.regName, tt.factory)
+		})
+	}
+}
+
+func TestInterceptorSettings_Decode(t *testing.T) {
+	type options struct {
+		Rate    float64       `yaml:"rate"`
+		Burst   int           `yaml:"burst"`
+		Timeout time.Duration `yaml:"timeout"`
+	}
+	defaults := options{Rate: 100, Burst: 200}
+
+	tests := []struct {
+		name     string
+		settings InterceptorSettings
+		want     options
+		wantErr  []string
+	}{
+		{name: "空配置块保留默认值", want: defaults},
+		{name: "只覆盖写了的键", settings: InterceptorSettings{"rate": 5, "timeout": "2s"}, want: options{Rate: 5, Burst: 200, Timeout: 2 * time.Second}},
+		{name: "未知的键按名字排序全部报告", settings: InterceptorSettings{"rate": 5, "brust": 1, "ratee": 2}, wantErr: []string{`未知的配置项 "brust"`, `未知的配置项 "ratee"`}},
+		{name: "类型错误去掉临时 yaml 的行号", settings: InterceptorSettings{"rate": "fast"}, wantErr: []string{"cannot unmarshal !!str `fast` into float64"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := defaults
+			err := tt.settings.Decode(&got)
+			if tt.wantErr == nil {
+				if err != nil || got != tt.want {
+					t.Errorf("Decode = %+v, %v，期望 %+v", got, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Decode 应该失败，得到 %+v", got)
+			}
+			if lines := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(lines, tt.wantErr) {
+				t.Errorf("错误 = %q，期望 %q", lines, tt.wantErr)
+			}
+			if strings.Contains(err.Error(), "line ") {
+				t.Errorf("错误里不应该有行号: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildInterceptors_AggregatesProblems(t *testing.T) {
+	_, err := NewServer(
+		WithInterceptors("auth", "tracing", "rate-limit", "metrics"),
+		WithInterceptorConfig("rate-limit", InterceptorSettings{"rate": 0}),
+		WithInterceptorConfig("metrics", InterceptorSettings{"slow": "1s"}),
+	)
+	var configErr *validate.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("错误 = %v，期望 ConfigError", err)
+	}
+	want := []string{
+		`未知的拦截器 "tracing"`,
+		"拦截器 auth: 启用了 auth 拦截器但没有设置JWT密钥",
+		"拦截器 rate-limit: rate 必须大于 0、burst 至少为 1，收到 rate=0 burst=200",
+		`拦截器 metrics: 未知的配置项 "slow"`,
+	}
+	if len(configErr.Problems) != len(want) {
+		t.Fatalf("问题 = %q\n期望 %d 个", configErr.Problems, len(want))
+	}
+	for i, problem := range configErr.Problems {
+		if !strings.Contains(problem, want[i]) {
+			t.Errorf("第 %d 个问题 = %q，期望包含 %q", i+1, problem, want[i])
+		}
+	}
+}
+
+func TestRateLimitInterceptor_Bounds(t *testing.T) {
+	tests := []struct {
+		settings InterceptorSettings
+		wantErr  string
+	}{
+		{nil, ""},
+		{InterceptorSettings{"rate": 0.5, "burst": 1}, ""},
+		{InterceptorSettings{"rate": 0}, "rate 必须大于 0"},
+		{InterceptorSettings{"rate": -1}, "rate 必须大于 0"},
+		{InterceptorSettings{"burst": 0}, "burst 至少为 1"},
+		{InterceptorSettings{"rate": "fast"}, "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		_, err := newRateLimitInterceptor(nil, tt.settings)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%v: 错误 = %v", tt.settings, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%v: 错误 = %v，期望包含 %q", tt.settings, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	dir := t.TempDir()
+	jwtFile := writeFile(t, dir, "jwt", "secret-v1\n")
+	server := newTestServer(t,
+		WithJWTAuth("file:"+jwtFile),
+		WithInterceptors("auth"),
+		WithInterceptorConfig("auth", InterceptorSettings{"skip_methods": []string{"/grpc.health.v1.Health/*"}}),
+	)
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		want   codes.Code
+	}{
+		{"正确的 Token", "/demo.Greeter/SayHello", "Bearer secret-v1", codes.OK},
+		{"错误的 Token", "/demo.Greeter/SayHello", "Bearer wrong", codes.Unauthenticated},
+		{"没带 Token", "/demo.Greeter/SayHello", "", codes.Unauthenticated},
+		{"skip_methods 不需要认证", "/grpc.health.v1.Health/Check", "", codes.OK},
+	}
+	for _, tt := range tests {
+		var pairs []string
+		if tt.token != "" {
+			pairs = []string{"authorization", tt.token}
+		}
+		if _, err := invoke(server, tt.method, pairs...); status.Code(err) != tt.want {
+			t.Errorf("%s: 错误 = %v，期望 %s", tt.name, err, tt.want)
+		}
+	}
+
+	// 密钥轮换后拦截器立即使用新密钥，不用重建拦截器链
+	writeFile(t, dir, "jwt", "secret-v2\n")
+	if _, err := server.RotateSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invoke(server, "/demo.Greeter/SayHello", "authorization", "Bearer secret-v1"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("轮换后旧密钥: 错误 = %v，期望 Unauthenticated", err)
+	}
+	if _, err := invoke(server, "/demo.Greeter/SayHello", "authorization", "Bearer secret-v2"); err != nil {
+		t.Errorf("轮换后新密钥: 错误 = %v", err)
+	}
+}
+
+func TestRegistry_ChainFromConfigFile(t *testing.T) {
+	// 第三方拦截器：给响应加上配置块里的前缀，用来观察链的顺序
+	registerForTest(t, "test-prefix", func(_ *Server, settings InterceptorSettings) (middleware.Interceptor, error) {
+		var options struct {
+			Prefix string `yaml:"prefix"`
+		}
+		if err := settings.Decode(&options); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, req interface{}, handler middleware.Handler) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return options.Prefix + resp.(string), nil
+		}, nil
+	})
+
+	configFile := writeFile(t, t.TempDir(), "config.yaml", `
+jwt_secret: registry-secret
+interceptors: [test-prefix, tenant, auth]
+interceptor_config:
+  test-prefix: {prefix: "已处理: "}
+  tenant: {allowed: [acme]}
+`)
+	loader := &ConfigLoader{LookupEnv: mapEnv(nil)}
+	config, err := loader.Load([]string{"--config", configFile})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	server := newTestServer(t, config.Options()...)
+
+	tests := []struct {
+		name     string
+		pairs    []string
+		wantResp interface{}
+		wantCode codes.Code
+	}{
+		{"按配置的顺序经过整条链", []string{"x-tenant-id", "acme", "authorization", "Bearer registry-secret"}, "已处理: req", codes.OK},
+		{"tenant 在 auth 之前拒绝", []string{"x-tenant-id", "initech"}, nil, codes.PermissionDenied},
+		{"tenant 通过后 auth 拒绝", []string{"x-tenant-id", "acme"}, nil, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		resp, err := invoke(server, "/demo.Greeter/SayHello", tt.pairs...)
+		if status.Code(err) != tt.wantCode || resp != tt.wantResp {
+			t.Errorf("%s: %v, %v，期望 %v, %s", tt.name, resp, err, tt.wantResp, tt.wantCode)
+		}
+	}
+
+	// 第三方拦截器自己的配置错误和内置拦截器一样汇总报告
+	_, err = NewServer(WithInterceptors("test-prefix"), WithInterceptorConfig("test-prefix", InterceptorSettings{"prefx": "x"}))
+	if err == nil || !strings.Contains(err.Error(), `拦截器 test-prefix: 未知的配置项 "prefx"`) {
+		t.Errorf("错误 = %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"frame_demo/redact"
+)
+
+// ==================== 密钥引用：env / file / keystore ====================
+// 密钥不应该以明文出现在代码和配置文件里。JWTSecret、Redis 密码可以写成引用：
+//
+//	jwt_secret: env:APP_JWT_SECRET                 # 环境变量
+//	jwt_secret: file:/var/run/secrets/app/jwt      # 文件（Kubernetes 挂载的 Secret）
+//	password:   keystore:redis-password            # 加密的本地密钥库
+//
+// NewServer 在启动时解析引用，Server 记住原始引用，RotateSecrets / WatchSecrets 会重新解析，
+// Kubernetes 更新挂载的 Secret 或者密钥库被替换后不用重启。
+// 前缀不是已注册的 provider 时按字面值处理（密码里本来就可能有冒号）。
+//
+// Secret 类型在 fmt 打印时自动打码，就算不小心 log.Printf("%+v", config) 也不会泄露。
+
+// Secret 敏感字符串：打印时自动打码，取原值需要显式调用 Value()
+type Secret string
+
+func (s Secret) String() string   { return redact.Mask(string(s)) }
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+func (s Secret) Value() string    { return string(s) }
+
+// SecretProvider 按名字取出密钥，每次调用都重新读取，轮换时才能拿到新值
+type SecretProvider interface {
+	Resolve(name string) (string, error)
+}
+
+// EnvSecretProvider env:NAME
+type EnvSecretProvider struct {
+	LookupEnv func(key string) (string, bool) // 默认 os.LookupEnv
+}
+
+func (p EnvSecretProvider) Resolve(name string) (string, error) {
+	lookup := p.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	value, ok := lookup(name)
+	if !ok {
+		return "", fmt.Errorf("环境变量 %s 未设置", name)
+	}
+	return value, nil
+}
+
+// FileSecretProvider file:/path，去掉末尾换行
+// Kubernetes 更新挂载的 Secret 时会原子地切换符号链接，每次重新读文件就能拿到新值
+type FileSecretProvider struct{}
+
+func (FileSecretProvider) Resolve(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// defaultSecretProviders 默认可用的 provider；keystore 需要口令，要用 WithSecretProvider 显式注册
+func defaultSecretProviders() map[string]SecretProvider {
+	return map[string]SecretProvider{
+		"env":  EnvSecretProvider{},
+		"file": FileSecretProvider{},
+	}
+}
+
+// WithSecretProvider 注册（或替换）一种密钥引用前缀
+func WithSecretProvider(scheme string, provider SecretProvider) Option {
+	return func(config *ServerConfig) error {
+		if scheme == "" || provider == nil {
+			return errors.New("WithSecretProvider: scheme 和 provider 都不能为空")
+		}
+		if config.secretProviders == nil {
+			config.secretProviders = defaultSecretProviders()
+		}
+		config.secretProviders[scheme] = provider
+		return nil
+	}
+}
+
+// ==================== 加密密钥库 ====================
+
+const keystoreIterations = 100_000
+
+// keystoreFile 密钥库文件格式：口令经 PBKDF2 派生出 AES-256-GCM 密钥，加密 name -> value 的 JSON
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// KeystoreSecretProvider keystore:NAME，口令一般来自环境变量，不和密钥库放在一起
+type KeystoreSecretProvider struct {
+	Path       string
+	Passphrase string
+}
+
+func (p *KeystoreSecretProvider) Resolve(name string) (string, error) {
+	secrets, err := OpenKeystore(p.Path, p.Passphrase)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("密钥库中没有 %q", name)
+	}
+	return value, nil
+}
+
+// SaveKeystore 加密保存密钥库（每次保存都用新的 salt 和 nonce）
+func SaveKeystore(path, passphrase string, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	ks := keystoreFile{Version: 1, Salt: make([]byte, 16)}
+	if _, err := rand.Read(ks.Salt); err != nil {
+		return err
+	}
+	gcm, err := keystoreCipher(passphrase, ks.Salt)
+	if err != nil {
+		return err
+	}
+	ks.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ks.Nonce); err != nil {
+		return err
+	}
+	ks.Ciphertext = gcm.Seal(nil, ks.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再改名，读的一方不会看到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// OpenKeystore 解密密钥库；口令错误和文件被篡改都会解密失败
+func OpenKeystore(path, passphrase string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥库失败: %v", err)
+	}
+	var ks keystoreFile
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("密钥库格式错误: %v", err)
+	}
+	if ks.Version != 1 {
+		return nil, fmt.Errorf("不支持的密钥库版本 %d", ks.Version)
+	}
+	gcm, err := keystoreCipher(passphrase, ks.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("密钥库格式错误: nonce 长度不对")
+	}
+	plaintext, err := gcm.Open(nil, ks.Nonce, ks.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("解密密钥库失败（口令错误或文件已损坏）")
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("密钥库格式错误: %v", err)
+	}
+	return secrets, nil
+}
+
+func keystoreCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("密钥库口令不能为空")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, keystoreIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ==================== 解析与轮换 ====================
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// secretFields 遍历配置中所有 Secret 字段，path 用配置文件里的键名，如 redis.password
+func secretFields(v reflect.Value, prefix string, fn func(path string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		fv := v.Field(i)
+		switch {
+		case field.Type == secretType:
+			fn(name, fv)
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			if !fv.IsNil() {
+				secretFields(fv.Elem(), name+".", fn)
+			}
+		}
+	}
+}
+
+// parseSecretRef 拆出 scheme:name，scheme 必须是已注册的 provider
+func parseSecretRef(value string, providers map[string]SecretProvider) (SecretProvider, string, bool) {
+	scheme, name, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", false
+	}
+	provider, ok := providers[scheme]
+	return provider, name, ok
+}
+
+// resolveSecrets 把配置中的引用替换成真实值，返回 字段 -> 原始引用（轮换时用）
+func resolveSecrets(config *ServerConfig) (map[string]string, []string) {
+	refs := map[string]string{}
+	var problems []string
+	secretFields(reflect.ValueOf(config).Elem(), "", func(path string, field reflect.Value) {
+		ref := field.String()
+		provider, name, ok := parseSecretRef(ref, config.secretProviders)
+		if !ok {
+			return
+		}
+		value, err := provider.Resolve(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("解析密钥 %s（%s）失败: %v", path, ref, err))
+			return
+		}
+		field.SetString(value)
+		refs[path] = ref
+	})
+	return refs, problems
+}
+
+// RotateSecrets 重新解析所有密钥引用，返回值发生变化的字段
+// 任何一个解析失败都保留旧值，不会出现一半新一半旧
+func (s *Server) RotateSecrets() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.config.clone()
+	var changed []string
+	var errs []error
+	secretFields(reflect.ValueOf(&next).Elem(), "", func(path string, field reflect.Value) {
+		ref, ok := s.secretRefs[path]
+		if !ok {
+			return
+		}
+		provider, name, _ := parseSecretRef(ref, s.config.secretProviders)
+		value, err := provider.Resolve(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("解析密钥 %s（%s）失败: %v", path, ref, err))
+			return
+		}
+		if value != field.String() {
+			field.SetString(value)
+			changed = append(changed, path)
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	sort.Strings(changed)
+	s.config = next
+	return changed, nil
+}
+
+// WatchSecrets 定期调用 RotateSecrets，直到 ctx 结束
+func (s *Server) WatchSecrets(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.RotateSecrets()
+			if err != nil {
+				log.Printf("⚠️  密钥轮换失败，继续使用旧值: %v", err)
+				continue
+			}
+			for _, path := range changed {
+				log.Printf("🔄 密钥已轮换: %s", path)
+			}
+		}
+	}
+}
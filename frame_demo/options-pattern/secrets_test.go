@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.keystore")
+	want := map[string]string{"jwt": "jwt-secret", "redis-password": "p@ss:word"}
+	if err := SaveKeystore(path, "passphrase", want); err != nil {
+		t.Fatalf("SaveKeystore: %v", err)
+	}
+
+	got, err := OpenKeystore(path, "passphrase")
+	if err != nil {
+		t.Fatalf("OpenKeystore: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("解密结果 = %v，期望 %v", got, want)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "jwt-secret") {
+		t.Error("密钥库文件里出现了明文")
+	}
+
+	if _, err := OpenKeystore(path, "wrong"); err == nil || !strings.Contains(err.Error(), "口令错误或文件已损坏") {
+		t.Errorf("口令错误: 错误 = %v", err)
+	}
+
+	// 改动密文的一个字节，GCM 校验失败
+	var ks keystoreFile
+	if err := json.Unmarshal(data, &ks); err != nil {
+		t.Fatal(err)
+	}
+	ks.Ciphertext[0] ^= 0xff
+	tampered, _ := json.Marshal(ks)
+	if err := os.WriteFile(path, tampered, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenKeystore(path, "passphrase"); err == nil || !strings.Contains(err.Error(), "口令错误或文件已损坏") {
+		t.Errorf("文件被篡改: 错误 = %v", err)
+	}
+}
+
+func TestSecretProviders(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "jwt", "file-secret\r\n")
+	keystore := filepath.Join(dir, "secrets.keystore")
+	if err := SaveKeystore(keystore, "passphrase", map[string]string{"jwt": "keystore-secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		provider SecretProvider
+		ref      string
+		want     string
+		wantErr  string
+	}{
+		{"环境变量", EnvSecretProvider{LookupEnv: mapEnv(map[string]string{"JWT": "env-secret"})}, "JWT", "env-secret", ""},
+		{"环境变量未设置", EnvSecretProvider{LookupEnv: mapEnv(nil)}, "JWT", "", "环境变量 JWT 未设置"},
+		{"文件去掉末尾换行", FileSecretProvider{}, file, "file-secret", ""},
+		{"文件不存在", FileSecretProvider{}, filepath.Join(dir, "missing"), "", "missing"},
+		{"密钥库", &KeystoreSecretProvider{Path: keystore, Passphrase: "passphrase"}, "jwt", "keystore-secret", ""},
+		{"密钥库中没有", &KeystoreSecretProvider{Path: keystore, Passphrase: "passphrase"}, "redis", "", `密钥库中没有 "redis"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.Resolve(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("错误 = %v，期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve = %q, %v，期望 %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretRef(t *testing.T) {
+	providers := defaultSecretProviders()
+	tests := []struct {
+		value    string
+		wantRef  bool
+		wantName string
+	}{
+		{"env:APP_JWT_SECRET", true, "APP_JWT_SECRET"},
+		{"file:/run/secrets/jwt", true, "/run/secrets/jwt"},
+		{"plain-secret", false, ""},
+		{"p@ss:word", false, ""},    // 密码里本来就有冒号，按字面值处理
+		{"keystore:jwt", false, ""}, // 没有注册的 scheme
+		{"env:A:B", true, "A:B"},    // 只按第一个冒号拆
+		{":no-scheme", false, ""},   // 空 scheme
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		provider, name, ok := parseSecretRef(tt.value, providers)
+		if ok != tt.wantRef || (ok && (name != tt.wantName || provider == nil)) {
+			t.Errorf("parseSecretRef(%q) = %v, %q, %v，期望 %q, %v", tt.value, provider, name, ok, tt.wantName, tt.wantRef)
+		}
+	}
+}
+
+func TestNewServer_ResolvesSecrets(t *testing.T) {
+	server := newTestServer(t,
+		WithJWTAuth("env:JWT_SECRET"),
+		WithSecretProvider("env", EnvSecretProvider{LookupEnv: mapEnv(map[string]string{"JWT_SECRET": "env-secret"})}),
+	)
+	if got := server.jwtSecret().Value(); got != "env-secret" {
+		t.Errorf("JWTSecret = %q，期望解析成 env-secret", got)
+	}
+
+	// 解析失败时 NewServer 报出字段和引用
+	_, err := NewServer(WithJWTAuth("env:MISSING"), WithSecretProvider("env", EnvSecretProvider{LookupEnv: mapEnv(nil)}))
+	if err == nil || !strings.Contains(err.Error(), "jwt_secret（env:MISSING）") {
+		t.Errorf("错误 = %v，期望指出 jwt_secret（env:MISSING）", err)
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	dir := t.TempDir()
+	jwtFile := writeFile(t, dir, "jwt", "jwt-v1\n")
+	redisFile := writeFile(t, dir, "redis", "redis-v1\n")
+	server := newTestServer(t,
+		WithJWTAuth("file:"+jwtFile),
+		WithRedis(miniredis.RunT(t).Addr(), "file:"+redisFile, 0),
+	)
+
+	// 没有变化
+	if changed, err := server.RotateSecrets(); err != nil || len(changed) != 0 {
+		t.Fatalf("RotateSecrets = %v, %v，期望没有变化", changed, err)
+	}
+
+	// 两个文件都更新了
+	writeFile(t, dir, "jwt", "jwt-v2\n")
+	writeFile(t, dir, "redis", "redis-v2\n")
+	changed, err := server.RotateSecrets()
+	if err != nil {
+		t.Fatalf("RotateSecrets: %v", err)
+	}
+	if want := []string{"jwt_secret", "redis.password"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("变化的字段 = %v，期望 %v", changed, want)
+	}
+	if got := server.jwtSecret().Value(); got != "jwt-v2" {
+		t.Errorf("JWTSecret = %q，期望 jwt-v2", got)
+	}
+
+	// JWT 文件更新了但 Redis 文件被删除：整体失败，两个都保留旧值
+	writeFile(t, dir, "jwt", "jwt-v3\n")
+	if err := os.Remove(redisFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RotateSecrets(); err == nil || !strings.Contains(err.Error(), "redis.password") {
+		t.Fatalf("错误 = %v，期望指出 redis.password", err)
+	}
+	config := server.Config()
+	if config.JWTSecret.Value() != "jwt-v2" || config.Redis.Password.Value() != "redis-v2" {
+		t.Errorf("解析失败后 JWTSecret = %q，Redis 密码 = %q，期望都保留旧值", config.JWTSecret.Value(), config.Redis.Password.Value())
+	}
+}
+
+func TestSecret_Masked(t *testing.T) {
+	config := ServerConfig{JWTSecret: "super-secret-value"}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(format, config); strings.Contains(out, "super-secret-value") {
+			t.Errorf("%s 打印出了明文: %s", format, out)
+		}
+	}
+}
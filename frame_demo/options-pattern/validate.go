@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"frame_demo/validate"
+)
+
+// ==================== 配置校验 ====================
+// 单个选项只能检查自己的参数，下面这些规则要等所有选项都应用完才能判断：
+//   - RefreshTTL 必须大于 AccessTTL
+//   - 拦截器名字必须已注册，拦截器配置块也只能给已注册的拦截器（各拦截器自己的参数由它的 factory 检查）
+//   - Redis 地址必须是 host:port，且只能设置当前模式用到的地址；cluster 模式不能选库
+//   - Redis 连接池、超时不能为负数，CA 证书只在启用 TLS 时有意义
+// 地址、日志级别的规则和 ConfigError 与 serverx-simplified 共用，见 frame_demo/validate。
+
+// configErrorSubject NewServer 返回的 ConfigError 开头
+const configErrorSubject = "服务器配置"
+
+// Validate 检查跨字段的规则，没有问题时返回 nil
+func (c *ServerConfig) Validate() error {
+	var problems []string
+
+	if err := validate.Address(c.ListenAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("监听地址 %q 不合法: %v", c.ListenAddr, err))
+	}
+
+	if c.RefreshTTL <= c.AccessTTL {
+		problems = append(problems, fmt.Sprintf("刷新令牌TTL（%v）必须大于访问令牌TTL（%v）", c.RefreshTTL, c.AccessTTL))
+	}
+
+	for _, name := range c.Interceptors {
+		if _, ok := lookupInterceptor(name); !ok {
+			problems = append(problems, unknownInterceptorProblem(name))
+		}
+	}
+	for _, name := range sortedKeys(c.InterceptorConfig) {
+		if _, ok := lookupInterceptor(name); !ok {
+			problems = append(problems, "interceptor_config: "+unknownInterceptorProblem(name))
+		}
+	}
+
+	if redis := c.GetRedis(); redis != nil {
+		problems = append(problems, validateRedisTopology(redis)...)
+		if redis.DB < 0 || redis.DB > 15 {
+			problems = append(problems, fmt.Sprintf("Redis数据库编号必须在 0-15 之间，收到 %d", redis.DB))
+		}
+		if redis.PoolSize < 0 || redis.MinIdleConns < 0 {
+			problems = append(problems, fmt.Sprintf("Redis连接池大小和最小空闲连接不能为负数，收到 %d/%d", redis.PoolSize, redis.MinIdleConns))
+		} else if redis.PoolSize > 0 && redis.MinIdleConns > redis.PoolSize {
+			problems = append(problems, fmt.Sprintf("Redis最小空闲连接（%d）不能超过连接池大小（%d）", redis.MinIdleConns, redis.PoolSize))
+		}
+		for _, timeout := range []struct {
+			name  string
+			value time.Duration
+		}{
+			{"连接超时", redis.DialTimeout}, {"读超时", redis.ReadTimeout}, {"写超时", redis.WriteTimeout}, {"健康检查间隔", redis.HealthCheckInterval},
+		} {
+			if timeout.value < 0 {
+				problems = append(problems, fmt.Sprintf("Redis%s不能为负数，收到 %v", timeout.name, timeout.value))
+			}
+		}
+		if redis.TLSCAFile != "" && !redis.TLS {
+			problems = append(problems, "设置了 Redis CA证书但没有启用 TLS")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &validate.ConfigError{Subject: configErrorSubject, Problems: problems}
+	}
+	return nil
+}
+
+// validateRedisTopology 按模式检查地址：每种模式只用自己的那组地址，设置了别的模式的字段多半是配置写错了
+func validateRedisTopology(redis *RedisConfig) []string {
+	var problems []string
+	checkAddrs := func(label string, addrs []string) {
+		for _, addr := range addrs {
+			if err := validate.Address(addr); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q 不合法: %v", label, addr, err))
+			}
+		}
+	}
+	unused := func(set bool, key string) {
+		if set {
+			problems = append(problems, fmt.Sprintf("Redis %s 模式不使用 %s", redisMode(redis), key))
+		}
+	}
+
+	switch redisMode(redis) {
+	case "standalone":
+		checkAddrs("Redis地址", []string{redis.Address})
+		unused(redis.MasterName != "" || len(redis.SentinelAddrs) > 0, "master_name/sentinel_addrs")
+		unused(len(redis.ClusterAddrs) > 0, "cluster_addrs")
+	case "sentinel":
+		if redis.MasterName == "" {
+			problems = append(problems, "Redis sentinel 模式必须设置主节点名（master_name）")
+		}
+		if len(redis.SentinelAddrs) == 0 {
+			problems = append(problems, "Redis sentinel 模式至少需要一个 Sentinel 地址（sentinel_addrs）")
+		}
+		checkAddrs("Sentinel地址", redis.SentinelAddrs)
+		unused(redis.Address != "", "address（主节点地址由 Sentinel 提供）")
+		unused(len(redis.ClusterAddrs) > 0, "cluster_addrs")
+	case "cluster":
+		if len(redis.ClusterAddrs) == 0 {
+			problems = append(problems, "Redis cluster 模式至少需要一个种子节点（cluster_addrs）")
+		}
+		checkAddrs("Cluster节点地址", redis.ClusterAddrs)
+		if redis.DB != 0 {
+			problems = append(problems, fmt.Sprintf("Redis cluster 模式只有 0 号库，不能设置 db（收到 %d）", redis.DB))
+		}
+		unused(redis.Address != "", "address（请使用 cluster_addrs）")
+		unused(redis.MasterName != "" || len(redis.SentinelAddrs) > 0, "master_name/sentinel_addrs")
+	default:
+		problems = append(problems, fmt.Sprintf("未知的 Redis 模式 %q（可选 standalone/sentinel/cluster）", redis.Mode))
+	}
+	return problems
+}
+
+// redisMode Mode 为空时是 standalone
+func redisMode(redis *RedisConfig) string {
+	if redis.Mode == "" {
+		return "standalone"
+	}
+	return redis.Mode
+}
+
+// errorLines 把错误拆成独立的问题：ConfigError 取 Problems，errors.Join 的结果逐个展开
+func errorLines(err error) []string {
+	if err == nil {
+		return nil
+	}
+	// 先展开 errors.Join：errors.As 会穿过 Join 找到里面的 ConfigError，和它并列的错误就丢了
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var lines []string
+		for _, e := range joined.Unwrap() {
+			lines = append(lines, errorLines(e)...)
+		}
+		return lines
+	}
+	var configErr *validate.ConfigError
+	if errors.As(err, &configErr) {
+		return configErr.Problems
+	}
+	return []string{err.Error()}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"frame_demo/validate"
+)
+
+// problemsOf 取出 ConfigError 里的问题，不是 ConfigError 时结束测试
+func problemsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var configErr *validate.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("期望 *validate.ConfigError，得到 %T: %v", err, err)
+	}
+	if !strings.HasPrefix(err.Error(), "服务器配置有") {
+		t.Errorf("错误信息开头不对: %q", err.Error())
+	}
+	return configErr.Problems
+}
+
+func TestNewServerAggregatesProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    []string // 每个问题的开头，按出现顺序
+	}{
+		{
+			name:    "默认配置合法",
+			options: nil,
+		},
+		{
+			name:    "选项错误和 Validate 错误一起返回",
+			options: []Option{WithJWTAuthAdvanced("", 2*time.Hour, time.Hour), WithLogging("verbose")},
+			want: []string{
+				"WithJWTAuthAdvanced: JWT 密钥不能为空",
+				`WithLogging: 未知的日志级别 "verbose"`,
+				"刷新令牌TTL（1h0m0s）必须大于访问令牌TTL（2h0m0s）",
+			},
+		},
+		{
+			name:    "出错的选项之后的选项仍然会应用和检查",
+			options: []Option{WithLogging("verbose"), WithListenAddr("0.0.0.0:0"), WithRedis("localhost", "", 0)},
+			want: []string{
+				`WithLogging: 未知的日志级别 "verbose"`,
+				`监听地址 "0.0.0.0:0" 不合法`,
+				`Redis地址 "localhost" 不合法`,
+			},
+		},
+		{
+			name:    "拦截器名字和拦截器 factory 的错误",
+			options: []Option{WithInterceptors("auth", "cache")},
+			want: []string{
+				`未知的拦截器 "cache"`,
+				"拦截器 auth: 启用了 auth 拦截器但没有设置JWT密钥",
+			},
+		},
+		{
+			name: "拦截器配置块的多个错误逐条展开",
+			options: []Option{
+				WithInterceptors("rate-limit"),
+				WithInterceptorConfig("rate-limit", InterceptorSettings{"rate": 0, "brust": 5}),
+			},
+			want: []string{`拦截器 rate-limit: 未知的配置项 "brust"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServer(tt.options...)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("NewServer: %v", err)
+				}
+				return
+			}
+			if server != nil {
+				t.Error("出错时不应该返回 Server")
+			}
+			problems := problemsOf(t, err)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("得到 %d 个问题 %q，期望 %d 个", len(problems), problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("问题 %d = %q，期望以 %q 开头", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"普通错误", errors.New("a"), []string{"a"}},
+		{"errors.Join 逐个展开", errors.Join(errors.New("a"), errors.New("b")), []string{"a", "b"}},
+		{"嵌套的 ConfigError", errors.Join(errors.New("a"), &validate.ConfigError{Problems: []string{"b", "c"}}), []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := errorLines(tt.err); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: errorLines() = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package redact 是 options-pattern 和 serverx-simplified 共用的密钥打码规则。
+//
+// 打印配置、输出差异、导出配置文件时，密钥都经过 Mask，
+// 只保留首尾两个字符方便核对是哪一把密钥，两边看到的格式完全一致。
+package redact
+
+// Mask 密钥打码：空值显示为“未设置”，4 个字符以内全部隐藏
+func Mask(secret string) string {
+	if secret == "" {
+		return "未设置"
+	}
+	if len(secret) <= 4 {
+		return "****"
+	}
+	return secret[:2] + "****" + secret[len(secret)-2:]
+}
@@ -0,0 +1,20 @@
+package redact
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		secret, want string
+	}{
+		{"", "未设置"},
+		{"abc", "****"},
+		{"abcd", "****"},
+		{"abcde", "ab****de"},
+		{"prod-secret-key", "pr****ey"},
+	}
+	for _, tt := range tests {
+		if got := Mask(tt.secret); got != tt.want {
+			t.Errorf("Mask(%q) = %q，期望 %q", tt.secret, got, tt.want)
+		}
+	}
+}
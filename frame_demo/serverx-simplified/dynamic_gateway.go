@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ==================== 动态转码：不再需要 protoc --grpc-gateway_out ====================
+// 生成的 RegisterGreeterHandlerFromEndpoint 做的事情其实很固定：
+//   1. 按 google.api.http 注解匹配路由
+//   2. 把 path / query / body 填进请求消息
+//   3. 调用 gRPC 方法，再把响应转成 JSON
+// 只要有描述符，这些都可以在运行时用 dynamicpb 完成，新服务只需要注册 gRPC 实现。
+
+// WithDynamicGateway - 对 protobufFiles 中带 HTTP 注解的方法动态生成 REST 接口
+func WithDynamicGateway() ServerOption {
+	return func(s *ServerX) error {
+		s.dynamicGateway = true
+		return nil
+	}
+}
+
+// WithDescriptorSet - 加载 FileDescriptorSet（protoc --descriptor_set_out --include_imports 生成）
+// 并为其中的服务启用动态转码，同时也会出现在 /openapi.json 里
+func WithDescriptorSet(path string) ServerOption {
+	return func(s *ServerX) error {
+		s.protobufFiles = append(s.protobufFiles, path)
+		s.dynamicGateway = true
+		return nil
+	}
+}
+
+// registerDynamicRoutes 把每条 HTTP 映射注册到 Gateway 的 ServeMux 上
+func (s *ServerX) registerDynamicRoutes(gwMux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	bindings, err := s.httpBindings()
+	if err != nil {
+		return err
+	}
+
+	for _, binding := range bindings {
+		md := binding.method
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			log.Printf("   ⏭️  跳过流式方法: %s", md.FullName())
+			continue
+		}
+
+		t := &dynamicTranscoder{binding: binding, mux: gwMux, conn: conn}
+		if err := gwMux.HandlePath(binding.verb, binding.path, t.ServeHTTP); err != nil {
+			return fmt.Errorf("注册动态路由 %s %s 失败: %v", binding.verb, binding.path, err)
+		}
+		log.Printf("   🔀 动态路由: %s %s -> %s", binding.verb, binding.path, fullMethodName(md))
+	}
+	return nil
+}
+
+// fullMethodName 返回 gRPC 调用用的方法名，如 /proto.Greeter/SayHello
+func fullMethodName(md protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+}
+
+// dynamicTranscoder 负责一条 HTTP 映射的 JSON <-> proto 转换
+type dynamicTranscoder struct {
+	binding httpBinding
+	mux     *runtime.ServeMux
+	conn    *grpc.ClientConn
+}
+
+func (t *dynamicTranscoder) ServeHTTP(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	ctx := r.Context()
+	inbound, outbound := runtime.MarshalerForRequest(t.mux, r)
+	md := t.binding.method
+
+	// 1. 把 HTTP 请求翻译成动态的请求消息
+	req := dynamicpb.NewMessage(md.Input())
+	if err := t.decodeRequest(r, inbound, req, pathParams); err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+
+	// 2. 和生成代码一样：转发 HTTP 头为 gRPC metadata（Authorization 等）
+	method := fullMethodName(md)
+	ctx, err := runtime.AnnotateContext(ctx, t.mux, r, method, runtime.WithHTTPPathPattern(t.binding.path))
+	if err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+
+	// 3. 调用 gRPC 方法（走完整的拦截器链）
+	resp := dynamicpb.NewMessage(md.Output())
+	var serverMD runtime.ServerMetadata
+	err = t.conn.Invoke(ctx, method, req, resp, grpc.Header(&serverMD.HeaderMD), grpc.Trailer(&serverMD.TrailerMD))
+	ctx = runtime.NewServerMetadataContext(ctx, serverMD)
+	if err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+
+	// 4. 写回响应（支持 response_body 只返回某个字段）
+	var out proto.Message = resp
+	if t.binding.responseBody != "" {
+		body, err := extractResponseBody(outbound, resp, t.binding.responseBody)
+		if err != nil {
+			runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+			return
+		}
+		out = responseBodyMessage{Message: resp, body: body}
+	}
+	runtime.ForwardResponseMessage(ctx, t.mux, outbound, w, r, out)
+}
+
+// decodeRequest 按 HttpRule 的约定填充请求：body -> path -> query
+func (t *dynamicTranscoder) decodeRequest(r *http.Request, inbound runtime.Marshaler, req *dynamicpb.Message, pathParams map[string]string) error {
+	switch t.binding.body {
+	case "":
+	case "*":
+		if err := inbound.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+			return status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	default:
+		// body 只映射到某个字段：包一层 {"field": <body>} 再解码，任何字段类型都适用
+		fd := req.Descriptor().Fields().ByName(protoreflect.Name(t.binding.body))
+		if fd == nil {
+			return status.Errorf(codes.Internal, "body 字段 %s 不存在", t.binding.body)
+		}
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		if len(raw) > 0 {
+			wrapped, _ := json.Marshal(map[string]json.RawMessage{fd.JSONName(): raw})
+			opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+			if err := opts.Unmarshal(wrapped, req); err != nil {
+				return status.Errorf(codes.InvalidArgument, "%v", err)
+			}
+		}
+	}
+
+	var filter [][]string
+	for name, value := range pathParams {
+		if err := runtime.PopulateFieldFromPath(req, name, value); err != nil {
+			return status.Errorf(codes.InvalidArgument, "路径参数 %s: %v", name, err)
+		}
+		filter = append(filter, []string{name})
+	}
+
+	// body 为 "*" 时所有字段都来自 body，不再解析 query
+	if t.binding.body == "*" {
+		return nil
+	}
+	if t.binding.body != "" {
+		filter = append(filter, []string{t.binding.body})
+	}
+	if err := r.ParseForm(); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := runtime.PopulateQueryParameters(req, r.Form, utilities.NewDoubleArray(filter)); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return nil
+}
+
+// responseBodyMessage 实现 gateway 约定的 XXX_ResponseBody，只输出指定字段
+type responseBodyMessage struct {
+	proto.Message
+	body interface{}
+}
+
+func (m responseBodyMessage) XXX_ResponseBody() interface{} {
+	return m.body
+}
+
+// extractResponseBody 先按 marshaler 的规则序列化整个响应，再取出 response_body 对应的字段
+func extractResponseBody(marshaler runtime.Marshaler, resp *dynamicpb.Message, field string) (json.RawMessage, error) {
+	fd := resp.Descriptor().Fields().ByName(protoreflect.Name(field))
+	if fd == nil {
+		return nil, status.Errorf(codes.Internal, "response_body 字段 %s 不存在", field)
+	}
+	all, err := marshaler.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(all, &fields); err != nil {
+		return nil, err
+	}
+	if raw, ok := fields[fd.JSONName()]; ok {
+		return raw, nil
+	}
+	return fields[fd.TextName()], nil
+}
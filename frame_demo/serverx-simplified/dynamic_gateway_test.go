@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	pb "grpc-learning/proto"
+)
+
+// greeterDescriptorSet protoc --descriptor_set_out --include_imports 生成的描述符集
+const greeterDescriptorSet = "../../grpc-learning/proto/greeter.binpb"
+
+// helloServer SayHello 返回 "你好, <name>"，让测试能看到请求字段是从哪里填进来的
+type helloServer struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (helloServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name 不能为空")
+	}
+	return &pb.HelloReply{Message: "你好, " + req.GetName()}, nil
+}
+
+// startDynamicGateway 启动 gRPC 服务和只包含动态路由的 Gateway，返回 HTTP 地址
+func startDynamicGateway(t *testing.T, opts ...ServerOption) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, helloServer{})
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s, err := NewServerX(append([]ServerOption{WithDescriptorSet(greeterDescriptorSet)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gwMux := runtime.NewServeMux()
+	if err := s.registerDynamicRoutes(gwMux, conn); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(gwMux)
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+func TestDynamicGateway(t *testing.T) {
+	url := startDynamicGateway(t, WithHTTPRuleConfig(writeRules(t, `    - selector: proto.Greeter.SayHello
+      post: /v1/sayhello
+      body: "*"
+      additional_bindings:
+        - get: /v1/greeter/{name}
+        - get: /v1/greeter
+          response_body: message
+        - put: /v1/greeter/name
+          body: name
+`)))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"body * 映射整个请求", "POST", "/v1/sayhello", `{"name":"张三"}`, 200, `{"message":"你好, 张三"}`},
+		{"body * 时忽略 query", "POST", "/v1/sayhello?name=李四", `{"name":"张三"}`, 200, `{"message":"你好, 张三"}`},
+		{"body * 时空 body", "POST", "/v1/sayhello?name=李四", ``, 400, ""},
+		{"路径参数", "GET", "/v1/greeter/%E7%8E%8B%E4%BA%94", "", 200, `{"message":"你好, 王五"}`},
+		{"路径参数优先于同名 query", "GET", "/v1/greeter/王五?name=李四", "", 200, `{"message":"你好, 王五"}`},
+		{"query 参数 + response_body 只返回一个字段", "GET", "/v1/greeter?name=李四", "", 200, `"你好, 李四"`},
+		{"body 映射到单个字段", "PUT", "/v1/greeter/name", `"赵六"`, 200, `{"message":"你好, 赵六"}`},
+		{"body 字段不会被同名 query 覆盖", "PUT", "/v1/greeter/name?name=李四", `"赵六"`, 200, `{"message":"你好, 赵六"}`},
+		{"body 类型不对", "PUT", "/v1/greeter/name", `{"x":1}`, 400, ""},
+		{"body 不是合法 JSON", "POST", "/v1/sayhello", `{name`, 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, url+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("HTTP %d，期望 %d: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(string(data)) != tt.wantBody {
+				t.Errorf("响应 = %s，期望 %s", data, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDynamicTranscoder_BadFields(t *testing.T) {
+	files, err := loadDescriptorSet(greeterDescriptorSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sayHello protoreflect.MethodDescriptor
+	for _, fd := range files {
+		if svc := fd.Services().ByName("Greeter"); svc != nil {
+			sayHello = svc.Methods().ByName("SayHello")
+		}
+	}
+	if sayHello == nil {
+		t.Fatal("描述符集里没有 proto.Greeter.SayHello")
+	}
+
+	// body 指向不存在的字段
+	transcoder := &dynamicTranscoder{binding: httpBinding{method: sayHello, body: "nickname"}}
+	req := httptest.NewRequest("POST", "/v1/sayhello", strings.NewReader(`"张三"`))
+	err = transcoder.decodeRequest(req, &runtime.JSONPb{}, dynamicpb.NewMessage(sayHello.Input()), nil)
+	if status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "body 字段 nickname 不存在") {
+		t.Errorf("错误 = %v，期望 body 字段 nickname 不存在", err)
+	}
+
+	// response_body 指向不存在的字段
+	resp := dynamicpb.NewMessage(sayHello.Output())
+	if _, err := extractResponseBody(&runtime.JSONPb{}, resp, "msg"); status.Code(err) != codes.Internal {
+		t.Errorf("错误 = %v，期望 Internal", err)
+	}
+	// 字段是零值时按 marshaler 的规则输出（JSONPb 默认不输出零值字段，得到空）
+	if raw, err := extractResponseBody(&runtime.JSONPb{}, resp, "message"); err != nil || raw != nil {
+		t.Errorf("extractResponseBody = %s, %v", raw, err)
+	}
+}
+
+func TestWithDescriptorSet_BadPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"文件不存在", "missing.binpb", "missing.binpb"},
+		{"不是描述符集", "http_rules.yaml", "不是合法的 FileDescriptorSet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewServerX(WithDescriptorSet(tt.path))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 = %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
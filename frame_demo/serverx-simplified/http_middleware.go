@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ==================== HTTP 中间件链（只作用于 Gateway 分支） ====================
+// gRPC 拦截器只能看到反序列化之后的 req，像压缩、CORS、请求体大小限制、访问日志
+// 这类“HTTP 专属”的关注点，必须在 HTTP 层处理。
+// 这里沿用 net/http 生态的标准写法：func(http.Handler) http.Handler
+
+// Module 框架模块的最小约定（JWTModule、LoggerModule 等都是模块）
+type Module interface {
+	Name() string
+}
+
+// UnaryInterceptorProvider 模块可选实现：向 gRPC 拦截器链贡献拦截器
+type UnaryInterceptorProvider interface {
+	Interceptor() grpc.UnaryServerInterceptor
+}
+
+// HTTPMiddlewareProvider 模块可选实现：向 HTTP Gateway 分支贡献中间件
+type HTTPMiddlewareProvider interface {
+	HTTPMiddlewares() []func(http.Handler) http.Handler
+}
+
+// scopedHTTPMiddleware 带路径前缀作用域的中间件，prefix 为空表示全局生效
+type scopedHTTPMiddleware struct {
+	prefix     string
+	middleware func(http.Handler) http.Handler
+}
+
+// WithHTTPMiddlewares - 添加全局 HTTP 中间件（按注册顺序，先注册的在最外层）
+func WithHTTPMiddlewares(middlewares ...func(http.Handler) http.Handler) ServerOption {
+	return WithHTTPMiddlewaresFor("", middlewares...)
+}
+
+// WithHTTPMiddlewaresFor - 添加只对指定路径前缀生效的 HTTP 中间件
+// 按路径段匹配："/v1/upload" 匹配 /v1/upload 和 /v1/upload/xxx，不匹配 /v1/uploads-admin
+func WithHTTPMiddlewaresFor(prefix string, middlewares ...func(http.Handler) http.Handler) ServerOption {
+	return func(s *ServerX) error {
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("WithHTTPMiddlewaresFor: 路径前缀 %q 必须以 / 开头", prefix)
+		}
+		for _, mw := range middlewares {
+			s.httpMiddlewares = append(s.httpMiddlewares, scopedHTTPMiddleware{prefix: prefix, middleware: mw})
+		}
+		return nil
+	}
+}
+
+// WithModules - 注册自定义模块，模块实现了哪些 Provider 接口，就自动接入哪条链
+func WithModules(modules ...Module) ServerOption {
+	return func(s *ServerX) error {
+		for _, m := range modules {
+			if m == nil {
+				return errors.New("WithModules: 模块不能为 nil")
+			}
+			s.modules = append(s.modules, m)
+			if p, ok := m.(UnaryInterceptorProvider); ok {
+				s.unaryInterceptors = append(s.unaryInterceptors, p.Interceptor())
+			}
+		}
+		return nil
+	}
+}
+
+// enabledModules 返回所有已启用的模块（内置模块在前，自定义模块在后）
+func (s *ServerX) enabledModules() []Module {
+	var modules []Module
+	if s.jwtModule != nil {
+		modules = append(modules, s.jwtModule)
+	}
+	if s.loggerModule != nil {
+		modules = append(modules, s.loggerModule)
+	}
+	return append(modules, s.modules...)
+}
+
+// buildHTTPHandler 把模块贡献的中间件和用户中间件包裹到 Gateway 外面
+// 顺序：模块中间件在最外层，然后是用户中间件，最里层是 gwMux
+func (s *ServerX) buildHTTPHandler(h http.Handler) http.Handler {
+	var chain []scopedHTTPMiddleware
+	for _, m := range s.enabledModules() {
+		if p, ok := m.(HTTPMiddlewareProvider); ok {
+			for _, mw := range p.HTTPMiddlewares() {
+				chain = append(chain, scopedHTTPMiddleware{middleware: mw})
+			}
+		}
+	}
+	chain = append(chain, s.httpMiddlewares...)
+
+	// 从后往前包裹，保证第一个中间件在最外层（和 Chain 的思路一样）
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i].wrap(h)
+	}
+	return h
+}
+
+func (m scopedHTTPMiddleware) wrap(next http.Handler) http.Handler {
+	wrapped := m.middleware(next)
+	if m.prefix == "" {
+		return wrapped
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pathHasPrefix(r.URL.Path, m.prefix) {
+			wrapped.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pathHasPrefix 按路径段判断 path 是否在 prefix 之下
+func pathHasPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// ==================== 常用 HTTP 中间件 ====================
+
+// CORSMiddleware 跨域中间件，origins 包含 "*" 时允许所有来源
+func CORSMiddleware(origins ...string) func(http.Handler) http.Handler {
+	return NewCORSPolicy(origins...).Middleware()
+}
+
+// CORSPolicy 允许的来源可以在运行时替换（配置热更新时用）
+type CORSPolicy struct {
+	allowed atomic.Pointer[map[string]bool]
+}
+
+func NewCORSPolicy(origins ...string) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.SetOrigins(origins...)
+	return p
+}
+
+func (p *CORSPolicy) SetOrigins(origins ...string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
+	}
+	p.allowed.Store(&allowed)
+}
+
+// Origins 当前允许的来源（排序后返回）
+func (p *CORSPolicy) Origins() []string {
+	var origins []string
+	for o := range *p.allowed.Load() {
+		origins = append(origins, o)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+func (p *CORSPolicy) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed := *p.allowed.Load()
+			origin := r.Header.Get("Origin")
+			if origin != "" && (allowed["*"] || allowed[origin]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Add("Vary", "Origin")
+			}
+			// 预检请求直接返回，不进入 Gateway
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodyLimitMiddleware 限制请求体大小，超出后 Gateway 解码会失败
+func BodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				http.Error(w, "请求体过大", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusRecorder 记录响应状态码，供访问日志使用
+// 包装之后原来的 ResponseWriter 能力不能丢：WebSocket 升级要 Hijack，SSE 要 Flush
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap 让 http.ResponseController 能拿到底层的 Flusher 等能力
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush 直接做类型断言的代码（如 w.(http.Flusher)）看不到 Unwrap，这里显式透传
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack gorilla/websocket 的 Upgrade 用 w.(http.Hijacker) 接管连接
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols // 101 是直接写在连接上的，WriteHeader 看不到
+	}
+	return conn, rw, err
+}
+
+// 日志模块的 HTTP 访问日志（日志模块同时贡献 gRPC 拦截器和 HTTP 中间件）
+func (l *LoggerModule) HTTPMiddlewares() []func(http.Handler) http.Handler {
+	if !l.enabled {
+		return nil
+	}
+	return []func(http.Handler) http.Handler{
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				start := time.Now()
+				rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+				next.ServeHTTP(rec, r)
+				fmt.Printf("📝 [AccessLog] %s %s -> %d, 耗时: %v\n", r.Method, r.URL.Path, rec.status, time.Since(start))
+			})
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPathHasPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		want         bool
+	}{
+		{"/v1/upload", "/v1/upload", true},
+		{"/v1/upload/avatar", "/v1/upload", true},
+		{"/v1/uploads-admin", "/v1/upload", false},
+		{"/v1/uploadx", "/v1/upload", false},
+		{"/v1/upload", "/v1/upload/", false},
+		{"/v1/upload/avatar", "/v1/upload/", true},
+		{"/v1/anything", "/", true},
+		{"/v2/upload", "/v1/upload", false},
+	}
+	for _, tt := range tests {
+		if got := pathHasPrefix(tt.path, tt.prefix); got != tt.want {
+			t.Errorf("pathHasPrefix(%q, %q) = %v，期望 %v", tt.path, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestScopedMiddlewareSegmentBoundary(t *testing.T) {
+	s := &ServerX{}
+	tag := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Scoped", "1")
+			next.ServeHTTP(w, r)
+		})
+	}
+	if err := WithHTTPMiddlewaresFor("/v1/upload", tag)(s); err != nil {
+		t.Fatal(err)
+	}
+	h := s.buildHTTPHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for path, want := range map[string]string{"/v1/upload": "1", "/v1/upload/a": "1", "/v1/uploads-admin": ""} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if got := rec.Header().Get("X-Scoped"); got != want {
+			t.Errorf("%s: X-Scoped = %q，期望 %q", path, got, want)
+		}
+	}
+}
+
+// 开启访问日志后，包装过的 ResponseWriter 仍然要支持 WebSocket 升级和 SSE 刷新
+func TestAccessLogKeepsHijackAndFlush(t *testing.T) {
+	logger := &LoggerModule{enabled: true}
+	logger.SetLevel("info")
+	accessLog := logger.HTTPMiddlewares()[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrade 已经写了错误响应
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	})
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			http.Error(w, "不支持 Flush", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: hi\n\n"))
+		w.(http.Flusher).Flush()
+	})
+	server := httptest.NewServer(accessLog(mux))
+	defer server.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("WebSocket 升级失败: %v（状态码 %d）", err, status)
+	}
+	defer conn.Close()
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "hello" {
+		t.Errorf("ReadMessage() = %q, %v", msg, err)
+	}
+
+	sse, err := http.Get(server.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sse.Body.Close()
+	if sse.StatusCode != http.StatusOK {
+		t.Errorf("SSE 状态码 %d，期望 200", sse.StatusCode)
+	}
+}
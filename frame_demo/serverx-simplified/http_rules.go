@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 外部 HTTP 规则配置 ====================
+// 别的团队维护的 proto 我们改不了，没法加 google.api.http 注解。
+// google/api/http.proto 里给出了另一种方式：在 service config 里写 http.rules，
+// 用 selector 指定方法。这里的规则会覆盖同名方法的注解，也可以给没注解的方法补上规则。
+// 合并后同一个 HTTP 方法 + 路径只能对应一个 gRPC 方法，和注解冲突时启动直接报错。
+//
+//	http:
+//	  rules:
+//	    - selector: proto.Greeter.SayHello
+//	      post: /v1/sayhello
+//	      body: "*"
+//	      additional_bindings:
+//	        - get: /v1/greeter/{name}
+
+// WithHTTPRuleConfig - 加载外部 HTTP 规则配置文件（YAML 或 JSON）
+func WithHTTPRuleConfig(paths ...string) ServerOption {
+	return func(s *ServerX) error {
+		s.httpRuleConfigs = append(s.httpRuleConfigs, paths...)
+		return nil
+	}
+}
+
+// httpBindings 汇总 protobufFiles 中所有方法的 HTTP 映射（注解 + 外部配置）
+func (s *ServerX) httpBindings() ([]httpBinding, error) {
+	files, err := s.resolveProtoFiles()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.loadHTTPRules(files)
+	if err != nil {
+		return nil, err
+	}
+	return collectHTTPBindings(files, overrides)
+}
+
+// loadHTTPRules 读取并校验所有外部规则，返回 selector -> HttpRule
+// 所有问题一次性报告，方便启动失败时一次改完
+func (s *ServerX) loadHTTPRules(files []protoreflect.FileDescriptor) (map[string]*annotations.HttpRule, error) {
+	if len(s.httpRuleConfigs) == 0 {
+		return nil, nil
+	}
+
+	methods := map[string]protoreflect.MethodDescriptor{}
+	for _, fd := range files {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			ms := services.Get(i).Methods()
+			for j := 0; j < ms.Len(); j++ {
+				methods[string(ms.Get(j).FullName())] = ms.Get(j)
+			}
+		}
+	}
+
+	rules := map[string]*annotations.HttpRule{}
+	var problems []string
+	for _, path := range s.httpRuleConfigs {
+		config, err := loadHTTPRuleConfig(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		for i, rule := range config.GetRules() {
+			where := fmt.Sprintf("%s: rules[%d]", path, i)
+			selector := rule.GetSelector()
+			md, ok := methods[selector]
+			switch {
+			case selector == "":
+				problems = append(problems, where+": 缺少 selector")
+				continue
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s: selector %s 在 protobufFiles 中找不到对应的方法", where, selector))
+				continue
+			case rules[selector] != nil:
+				problems = append(problems, fmt.Sprintf("%s: selector %s 重复定义", where, selector))
+				continue
+			}
+			problems = append(problems, validateHTTPRule(where+" ("+selector+")", md, rule, false)...)
+			rules[selector] = rule
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("HTTP 规则配置有 %d 处错误:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return rules, nil
+}
+
+// loadHTTPRuleConfig 解析 service config 文件中的 http 段
+// YAML 先转成 JSON，再交给 protojson 严格解析，拼错的字段名会直接报错
+func loadHTTPRuleConfig(path string) (*annotations.Http, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("YAML 格式错误: %v", err)
+	}
+	section, ok := doc["http"]
+	if !ok {
+		return nil, fmt.Errorf("缺少 http 配置段")
+	}
+	raw, err := json.Marshal(section)
+	if err != nil {
+		return nil, fmt.Errorf("http 配置段格式错误: %v", err)
+	}
+
+	config := &annotations.Http{}
+	if err := protojson.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("http 配置段格式错误: %v", err)
+	}
+	return config, nil
+}
+
+// validateHTTPRule 按 http.proto 的约定检查一条规则（含 additional_bindings）
+func validateHTTPRule(where string, md protoreflect.MethodDescriptor, rule *annotations.HttpRule, nested bool) []string {
+	var problems []string
+	verb, path := ruleVerbAndPath(rule)
+	if verb == "" || path == "" {
+		problems = append(problems, where+": 缺少 get/put/post/delete/patch/custom 路径")
+	} else {
+		if err := validatePathTemplate(path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: 路径 %s 不合法: %v", where, path, err))
+		} else {
+			_, params := pathTemplateToOpenAPI(path)
+			for _, p := range params {
+				if !hasFieldPath(md.Input(), p) {
+					problems = append(problems, fmt.Sprintf("%s: 路径变量 %s 在 %s 中不存在", where, p, md.Input().FullName()))
+				}
+			}
+		}
+		if rule.GetBody() != "" && (verb == http.MethodGet || verb == http.MethodDelete) {
+			problems = append(problems, fmt.Sprintf("%s: %s 请求不能设置 body", where, verb))
+		}
+	}
+
+	if body := rule.GetBody(); body != "" && body != "*" && md.Input().Fields().ByName(protoreflect.Name(body)) == nil {
+		problems = append(problems, fmt.Sprintf("%s: body 字段 %s 在 %s 中不存在", where, body, md.Input().FullName()))
+	}
+	if rb := rule.GetResponseBody(); rb != "" && md.Output().Fields().ByName(protoreflect.Name(rb)) == nil {
+		problems = append(problems, fmt.Sprintf("%s: response_body 字段 %s 在 %s 中不存在", where, rb, md.Output().FullName()))
+	}
+
+	for i, additional := range rule.GetAdditionalBindings() {
+		sub := fmt.Sprintf("%s.additional_bindings[%d]", where, i)
+		if nested {
+			problems = append(problems, sub+": additional_bindings 不能嵌套")
+			continue
+		}
+		if additional.GetSelector() != "" {
+			problems = append(problems, sub+": additional_bindings 中不能设置 selector")
+		}
+		problems = append(problems, validateHTTPRule(sub, md, additional, true)...)
+	}
+	return problems
+}
+
+// validatePathTemplate 做基本的语法检查：以 / 开头、花括号成对且不嵌套、变量名非空
+func validatePathTemplate(template string) error {
+	if !strings.HasPrefix(template, "/") {
+		return fmt.Errorf("必须以 / 开头")
+	}
+	depth := 0
+	for i, c := range template {
+		switch c {
+		case '{':
+			if depth > 0 {
+				return fmt.Errorf("第 %d 个字符: 变量不能嵌套", i)
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				return fmt.Errorf("第 %d 个字符: 多余的 }", i)
+			}
+			depth--
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("缺少 }")
+	}
+	if strings.Contains(template, "{}") || strings.Contains(template, "{=") {
+		return fmt.Errorf("变量名不能为空")
+	}
+	return nil
+}
+
+// hasFieldPath 检查 a.b.c 这样的字段路径在消息中是否存在
+func hasFieldPath(md protoreflect.MessageDescriptor, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		if md == nil {
+			return false
+		}
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return false
+		}
+		md = fd.Message()
+	}
+	return true
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ==================== OpenAPI 文档：让 protobufFiles 真正派上用场 ====================
+// .proto 里的 google.api.http 注解本来就描述了 REST 接口长什么样，
+// 框架只需要读一遍描述符，就能自动生成 OpenAPI v3 文档，业务开发者不用手写 swagger。
+
+//go:embed explorer.html
+var explorerHTML []byte
+
+// WithProtobufFiles - 指定需要生成文档的 proto 文件
+// 既可以是生成代码里注册的路径（如 "greeter.proto"），也可以是 FileDescriptorSet 文件路径
+func WithProtobufFiles(files ...string) ServerOption {
+	return func(s *ServerX) error {
+		s.protobufFiles = append(s.protobufFiles, files...)
+		return nil
+	}
+}
+
+// SecuritySchemeProvider 模块可选实现：声明自己带来的认证要求，写进 OpenAPI 文档
+type SecuritySchemeProvider interface {
+	SecurityScheme() (name string, scheme map[string]interface{})
+}
+
+func (j *JWTModule) SecurityScheme() (string, map[string]interface{}) {
+	return "bearerAuth", map[string]interface{}{
+		"type":         "http",
+		"scheme":       "bearer",
+		"bearerFormat": "JWT",
+	}
+}
+
+// httpBinding 一条 HTTP 映射规则（一个 gRPC 方法可能有多条，来自 additional_bindings）
+type httpBinding struct {
+	method       protoreflect.MethodDescriptor
+	verb         string // GET / POST / ...
+	path         string // 原始路径模板，如 /v1/{name=users/*}
+	body         string
+	responseBody string
+	source       string // 规则来源：google.api.http 注解或外部 HTTP 规则，冲突时用于报错
+}
+
+// resolveProtoFiles 把 protobufFiles 解析成文件描述符
+// 先查全局注册表（生成代码 init 时注册的），找不到再当作描述符集文件读取
+func (s *ServerX) resolveProtoFiles() ([]protoreflect.FileDescriptor, error) {
+	var files []protoreflect.FileDescriptor
+	for _, name := range s.protobufFiles {
+		if fd, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+			files = append(files, fd)
+			continue
+		}
+
+		fds, err := loadDescriptorSet(name)
+		if err != nil {
+			return nil, fmt.Errorf("解析 proto 文件 %s 失败: %v", name, err)
+		}
+		files = append(files, fds...)
+	}
+	return files, nil
+}
+
+// loadDescriptorSet 读取 protoc --descriptor_set_out 生成的文件（需带 --include_imports）
+func loadDescriptorSet(path string) ([]protoreflect.FileDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("不是合法的 FileDescriptorSet: %v", err)
+	}
+	registry, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []protoreflect.FileDescriptor
+	for _, f := range set.GetFile() {
+		fd, err := registry.FindFileByPath(f.GetName())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fd)
+	}
+	return files, nil
+}
+
+// collectHTTPBindings 遍历所有服务方法，读取 google.api.http 注解
+// overrides 中有同名 selector 时，以外部配置为准
+// 两条映射的 HTTP 方法和路径相同时返回错误，否则后注册的会悄悄覆盖前一个
+func collectHTTPBindings(files []protoreflect.FileDescriptor, overrides map[string]*annotations.HttpRule) ([]httpBinding, error) {
+	var bindings []httpBinding
+	for _, fd := range files {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				md := methods.Get(j)
+				rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+				source := "google.api.http 注解"
+				if override, found := overrides[string(md.FullName())]; found {
+					rule, ok, source = override, true, "外部 HTTP 规则"
+				}
+				if !ok || rule == nil {
+					continue
+				}
+				bindings = append(bindings, bindingsFromRule(md, rule, source)...)
+			}
+		}
+	}
+	if problems := duplicateBindings(bindings); len(problems) > 0 {
+		return nil, fmt.Errorf("HTTP 映射有 %d 处冲突:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return bindings, nil
+}
+
+// duplicateBindings 找出 HTTP 方法 + 路径相同的映射
+// 路径变量只比较名字：/v1/{name} 和 /v1/{name=*} 在 OpenAPI 和路由里是同一个路径
+func duplicateBindings(bindings []httpBinding) []string {
+	var problems []string
+	seen := map[string]httpBinding{}
+	for _, b := range bindings {
+		path, _ := pathTemplateToOpenAPI(b.path)
+		key := b.verb + " " + path
+		if first, ok := seen[key]; ok {
+			problems = append(problems, fmt.Sprintf("%s 同时映射到 %s（%s）和 %s（%s）",
+				key, first.method.FullName(), first.source, b.method.FullName(), b.source))
+			continue
+		}
+		seen[key] = b
+	}
+	return problems
+}
+
+// bindingsFromRule 把一条 HttpRule（含 additional_bindings）展开成多条映射
+func bindingsFromRule(md protoreflect.MethodDescriptor, rule *annotations.HttpRule, source string) []httpBinding {
+	var bindings []httpBinding
+	if verb, path := ruleVerbAndPath(rule); verb != "" {
+		bindings = append(bindings, httpBinding{
+			method:       md,
+			verb:         verb,
+			path:         path,
+			body:         rule.GetBody(),
+			responseBody: rule.GetResponseBody(),
+			source:       source,
+		})
+	}
+	for _, additional := range rule.GetAdditionalBindings() {
+		bindings = append(bindings, bindingsFromRule(md, additional, source)...)
+	}
+	return bindings
+}
+
+func ruleVerbAndPath(rule *annotations.HttpRule) (string, string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// pathTemplateToOpenAPI 把 /v1/{name=users/*}:cancel 转成 /v1/{name}:cancel，并返回路径参数
+func pathTemplateToOpenAPI(template string) (string, []string) {
+	var b strings.Builder
+	var params []string
+	for i := 0; i < len(template); i++ {
+		if template[i] != '{' {
+			b.WriteByte(template[i])
+			continue
+		}
+		end := strings.IndexByte(template[i:], '}')
+		if end < 0 {
+			b.WriteString(template[i:])
+			break
+		}
+		field := template[i+1 : i+end]
+		if eq := strings.IndexByte(field, '='); eq >= 0 {
+			field = field[:eq]
+		}
+		params = append(params, field)
+		b.WriteString("{" + field + "}")
+		i += end
+	}
+	return b.String(), params
+}
+
+// ==================== 生成 OpenAPI v3 文档 ====================
+
+type openAPIBuilder struct {
+	schemas map[string]interface{}
+}
+
+// operationIDs 按 bindings 的顺序给每条映射分配 operationId（Service_Method）。
+// additional_bindings 会让同一个方法出现多次，从第二条起加序号；
+// 加了序号仍可能撞上别的方法（如 Get 的第二条映射 Get2 和方法 Get2），或者不同包里的同名服务，这时返回错误。
+// NewServerX 的 validate 用它提前发现重复，OpenAPISpec 用它填写文档
+func operationIDs(bindings []httpBinding) ([]string, error) {
+	ids := make([]string, len(bindings))
+	counts := map[string]int{}
+	used := map[string]string{} // 最终的 operationId -> 方法全名
+	for i, binding := range bindings {
+		md := binding.method
+		id := fmt.Sprintf("%s_%s", md.Parent().Name(), md.Name())
+		final := id
+		if n := counts[id]; n > 0 {
+			final = fmt.Sprintf("%s%d", id, n+1)
+		}
+		counts[id]++
+		if other, ok := used[final]; ok {
+			return nil, fmt.Errorf("operationId %s 重复：%s 和 %s", final, other, md.FullName())
+		}
+		used[final] = string(md.FullName())
+		ids[i] = final
+	}
+	return ids, nil
+}
+
+// OpenAPISpec 根据 protobufFiles 和已启用的模块生成 OpenAPI v3 文档
+func (s *ServerX) OpenAPISpec() ([]byte, error) {
+	bindings, err := s.httpBindings()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &openAPIBuilder{schemas: map[string]interface{}{
+		"google.rpc.Status": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"code":    map[string]interface{}{"type": "integer", "format": "int32"},
+				"message": map[string]interface{}{"type": "string"},
+				"details": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object"}},
+			},
+		},
+	}}
+
+	ids, err := operationIDs(bindings)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]interface{}{}
+	for i, binding := range bindings {
+		path, params := pathTemplateToOpenAPI(binding.path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		op := b.operation(binding, params)
+		op["operationId"] = ids[i]
+		item[strings.ToLower(binding.verb)] = op
+	}
+
+	components := map[string]interface{}{"schemas": b.schemas}
+	spec := map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": "ServerX API", "version": "v1"},
+		"paths":      paths,
+		"components": components,
+	}
+
+	// 认证要求由启用的模块决定：开了 JWT 模块，所有接口就都需要 Bearer Token
+	schemes := map[string]interface{}{}
+	var security []interface{}
+	for _, m := range s.enabledModules() {
+		if p, ok := m.(SecuritySchemeProvider); ok {
+			name, scheme := p.SecurityScheme()
+			schemes[name] = scheme
+			security = append(security, map[string]interface{}{name: []string{}})
+		}
+	}
+	if len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+		spec["security"] = security
+	}
+
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+func (b *openAPIBuilder) operation(binding httpBinding, pathParams []string) map[string]interface{} {
+	md := binding.method
+	service := md.Parent().(protoreflect.ServiceDescriptor)
+
+	inPath := map[string]bool{}
+	var parameters []interface{}
+	for _, p := range pathParams {
+		inPath[p] = true
+		parameters = append(parameters, map[string]interface{}{
+			"name":     p,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	// 没有被 path 和 body 占用的顶层字段，都可以通过 query 传
+	if binding.body != "*" {
+		fields := md.Input().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if inPath[string(fd.Name())] || string(fd.Name()) == binding.body || fd.Kind() == protoreflect.MessageKind || fd.IsMap() {
+				continue
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":   fd.JSONName(),
+				"in":     "query",
+				"schema": b.fieldSchema(fd),
+			})
+		}
+	}
+
+	op := map[string]interface{}{
+		"tags":    []string{string(service.Name())},
+		"summary": fmt.Sprintf("/%s/%s", service.FullName(), md.Name()),
+		"responses": map[string]interface{}{
+			"200": jsonContent("成功", b.responseSchema(binding)),
+			"default": jsonContent("错误", map[string]interface{}{
+				"$ref": "#/components/schemas/google.rpc.Status",
+			}),
+		},
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	switch binding.body {
+	case "":
+	case "*":
+		op["requestBody"] = jsonContent("", b.messageSchema(md.Input()))
+	default:
+		if fd := md.Input().Fields().ByName(protoreflect.Name(binding.body)); fd != nil {
+			op["requestBody"] = jsonContent("", b.fieldSchema(fd))
+		}
+	}
+	if body, ok := op["requestBody"].(map[string]interface{}); ok {
+		body["required"] = true
+	}
+	return op
+}
+
+func (b *openAPIBuilder) responseSchema(binding httpBinding) map[string]interface{} {
+	output := binding.method.Output()
+	if binding.responseBody != "" {
+		if fd := output.Fields().ByName(protoreflect.Name(binding.responseBody)); fd != nil {
+			return b.fieldSchema(fd)
+		}
+	}
+	return b.messageSchema(output)
+}
+
+func jsonContent(description string, schema map[string]interface{}) map[string]interface{} {
+	content := map[string]interface{}{
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+	if description != "" {
+		content["description"] = description
+	}
+	return content
+}
+
+// messageSchema 消息类型统一放进 components/schemas，用 $ref 引用（天然支持递归消息）
+func (b *openAPIBuilder) messageSchema(md protoreflect.MessageDescriptor) map[string]interface{} {
+	if wkt := wellKnownSchema(md.FullName()); wkt != nil {
+		return wkt
+	}
+
+	name := string(md.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		b.schemas[name] = nil // 先占位，防止递归消息死循环
+
+		properties := map[string]interface{}{}
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			properties[fd.JSONName()] = b.fieldSchema(fd)
+		}
+		b.schemas[name] = map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *openAPIBuilder) fieldSchema(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": b.singularSchema(fd.MapValue()),
+		}
+	}
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": b.singularSchema(fd)}
+	}
+	return b.singularSchema(fd)
+}
+
+// singularSchema 按 protojson 的编码规则映射类型（如 int64 在 JSON 里是字符串）
+func (b *openAPIBuilder) singularSchema(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]interface{}{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]interface{}{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.EnumKind:
+		var values []string
+		enumValues := fd.Enum().Values()
+		for i := 0; i < enumValues.Len(); i++ {
+			values = append(values, string(enumValues.Get(i).Name()))
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.messageSchema(fd.Message())
+	}
+	return map[string]interface{}{}
+}
+
+// wellKnownSchema 常用 Well-Known Types 在 JSON 里有特殊表示
+func wellKnownSchema(name protoreflect.FullName) map[string]interface{} {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return map[string]interface{}{"type": "string"}
+	case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Empty":
+		return map[string]interface{}{"type": "object"}
+	case "google.protobuf.Value":
+		return map[string]interface{}{}
+	case "google.protobuf.ListValue":
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+	case "google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return map[string]interface{}{"type": "string"}
+	case "google.protobuf.BoolValue":
+		return map[string]interface{}{"type": "boolean"}
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return map[string]interface{}{"type": "integer"}
+	case "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return map[string]interface{}{"type": "number"}
+	}
+	return nil
+}
+
+// ==================== 挂载文档路由 ====================
+
+// mountAPIDocs 在 HTTP 分支上挂载 /openapi.json 和离线的 API 调试页面 /docs
+func (s *ServerX) mountAPIDocs(mux *http.ServeMux) error {
+	if len(s.protobufFiles) == 0 {
+		return nil
+	}
+
+	// 启动时生成一次即可，描述符在运行期间不会变化
+	spec, err := s.OpenAPISpec()
+	if err != nil {
+		return err
+	}
+
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(explorerHTML)
+	})
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	_ "grpc-learning/proto" // 注册 greeter.proto
+)
+
+// writeRules 在临时目录写一个外部 HTTP 规则文件
+func writeRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "http_rules.yaml")
+	if err := os.WriteFile(path, []byte("http:\n  rules:\n"+rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHTTPBindings_Duplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{
+			name: "外部规则和注解冲突",
+			rules: `    - selector: proto.Greeter.SayHelloStream
+      post: /v1/chat
+      body: "*"
+`,
+			wantErr: "POST /v1/chat 同时映射到 proto.Greeter.SayHelloStream（外部 HTTP 规则）和 proto.Greeter.Chat（google.api.http 注解）",
+		},
+		{
+			name: "additional_bindings 和主规则冲突，路径变量写法不同也算",
+			rules: `    - selector: proto.Greeter.SayHello
+      get: /v1/greeter/{name}
+      additional_bindings:
+        - get: /v1/greeter/{name=*}
+`,
+			wantErr: "GET /v1/greeter/{name} 同时映射到 proto.Greeter.SayHello（外部 HTTP 规则）和 proto.Greeter.SayHello（外部 HTTP 规则）",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewServerX(WithProtobufFiles("greeter.proto"), WithHTTPRuleConfig(writeRules(t, tt.rules)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 = %v\n期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAPISpec_WithHTTPRules(t *testing.T) {
+	server, err := NewServerX(WithProtobufFiles("greeter.proto"), WithHTTPRuleConfig("http_rules.yaml"))
+	if err != nil {
+		t.Fatalf("NewServerX: %v", err)
+	}
+	data, err := server.OpenAPISpec()
+	if err != nil {
+		t.Fatalf("OpenAPISpec: %v", err)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for path, item := range spec.Paths {
+		for verb, op := range item {
+			if ids[op.OperationID] {
+				t.Errorf("%s %s: operationId %s 重复", verb, path, op.OperationID)
+			}
+			ids[op.OperationID] = true
+		}
+	}
+	for _, id := range []string{"Greeter_SayHello", "Greeter_SayHello2", "Greeter_Chat"} {
+		if !ids[id] {
+			t.Errorf("缺少 operationId %s，得到 %v", id, ids)
+		}
+	}
+}
+
+// writeDescriptorSet 生成一个 demo.Items 服务的描述符集：
+// Get 有两条映射（第二条的 operationId 是 Items_Get2），另外还有一个叫 Get2 的方法
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	method := func(name, path string, additional ...string) *descriptorpb.MethodDescriptorProto {
+		rule := &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: path}}
+		for _, p := range additional {
+			rule.AdditionalBindings = append(rule.AdditionalBindings, &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: p}})
+		}
+		options := &descriptorpb.MethodOptions{}
+		proto.SetExtension(options, annotations.E_Http, rule)
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".demo.Item"),
+			OutputType: proto.String(".demo.Item"),
+			Options:    options,
+		}
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("demo/items.proto"),
+		Package: proto.String("demo"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("id"),
+				JsonName: proto.String("id"),
+				Number:   proto.Int32(1),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Items"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Get", "/v1/items/{id}", "/v1/items:get"),
+				method("Get2", "/v2/items/{id}"),
+			},
+		}},
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "items.binpb")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewServerX_DuplicateOperationID(t *testing.T) {
+	// 路径不冲突，但 Get 的第二条映射 Get2 和方法 Get2 撞名，构造时就报告，不用等到 Run 挂载文档
+	_, err := NewServerX(WithProtobufFiles(writeDescriptorSet(t)))
+	if err == nil || !strings.Contains(err.Error(), "operationId Items_Get2 重复：demo.Items.Get 和 demo.Items.Get2") {
+		t.Errorf("错误 = %v，期望 operationId Items_Get2 重复", err)
+	}
+}
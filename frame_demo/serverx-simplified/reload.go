@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"frame_demo/middleware"
+	"frame_demo/redact"
+	"frame_demo/validate"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== 配置热更新 ====================
+// 改日志级别、调限流不应该需要重启。WithConfigFile 指定一个 YAML 文件：
+//
+//	address: 0.0.0.0:8080          # 不可热更新，改了会被拒绝
+//	log_level: info
+//	rate_limit: {rate: 100, burst: 200}
+//	cors_origins: [https://app.example.com]
+//	jwt_secrets: [new-key, old-key] # 任意一个匹配即通过，轮换期间新旧并存
+//
+// Run 之后文件变化（或收到 SIGHUP）会触发 Reload：
+//  1. 重新读取并校验，有任何错误就整体放弃，继续使用当前配置
+//  2. 和当前生效的配置逐项对比，打印差异
+//  3. 可热更新的项原子替换（每个模块内部用 atomic 指针，正在处理的请求不受影响）
+//  4. 不可热更新的项（监听地址、启动时没有启用的模块）拒绝并记录，需要重启才能生效
+//
+// 文件里没写的项保持不变；文件中的值优先于代码里的 With... 选项。
+
+// RuntimeConfig 配置文件的内容
+type RuntimeConfig struct {
+	Address     string           `yaml:"address" reload:"restart"`
+	LogLevel    string           `yaml:"log_level"`
+	RateLimit   *RateLimitConfig `yaml:"rate_limit"`
+	CORSOrigins []string         `yaml:"cors_origins"`
+	JWTSecrets  []string         `yaml:"jwt_secrets" secret:"true"`
+}
+
+// RateLimitConfig 令牌桶参数
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+func (c RateLimitConfig) String() string {
+	return fmt.Sprintf("rate=%v burst=%d", c.Rate, c.Burst)
+}
+
+// WithConfigFile - 从 YAML 文件加载配置，Run 之后监听文件变化和 SIGHUP 自动热更新
+func WithConfigFile(path string) ServerOption {
+	return func(s *ServerX) error {
+		if path == "" {
+			return errors.New("WithConfigFile: 路径不能为空")
+		}
+		s.configFile = path
+		return nil
+	}
+}
+
+// WithRateLimit - 全局令牌桶限流，速率可以热更新
+func WithRateLimit(rate float64, burst int) ServerOption {
+	return func(s *ServerX) error {
+		if err := validateRateLimit(RateLimitConfig{Rate: rate, Burst: burst}); err != nil {
+			return fmt.Errorf("WithRateLimit: %v", err)
+		}
+		s.rateLimiter = middleware.NewRateLimiter(rate, burst)
+		if s.unaryNames == nil {
+			s.unaryNames = map[int]string{}
+		}
+		s.unaryNames[len(s.unaryInterceptors)] = "RateLimit"
+		s.unaryInterceptors = append(s.unaryInterceptors, middleware.ToUnaryServerInterceptor(s.rateLimiter.Interceptor()))
+		return nil
+	}
+}
+
+// WithCORS - 全局跨域中间件，允许的来源可以热更新
+func WithCORS(origins ...string) ServerOption {
+	return func(s *ServerX) error {
+		if len(origins) == 0 {
+			return errors.New("WithCORS: 至少需要一个来源")
+		}
+		s.cors = NewCORSPolicy(origins...)
+		s.httpMiddlewares = append(s.httpMiddlewares, scopedHTTPMiddleware{middleware: s.cors.Middleware()})
+		return nil
+	}
+}
+
+func validateRateLimit(c RateLimitConfig) error {
+	if c.Rate <= 0 || c.Burst < 1 {
+		return fmt.Errorf("限流参数不合法（rate 必须大于 0，burst 至少为 1）: %v", c)
+	}
+	return nil
+}
+
+// readRuntimeConfig 读取配置文件，未知的键视为错误
+func readRuntimeConfig(path string) (*RuntimeConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config := &RuntimeConfig{}
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	// CORS 来源是集合：排序后和 CORSPolicy.Origins() 一致，只是顺序不同不算变化
+	sort.Strings(config.CORSOrigins)
+	return config, nil
+}
+
+// validate 检查文件中出现的项
+func (c *RuntimeConfig) validate() []string {
+	var problems []string
+	if c.Address != "" {
+		if err := validate.Address(c.Address); err != nil {
+			problems = append(problems, fmt.Sprintf("address %q 不合法: %v", c.Address, err))
+		}
+	}
+	if c.LogLevel != "" {
+		if err := validate.LogLevel(c.LogLevel); err != nil {
+			problems = append(problems, "log_level: "+err.Error())
+		}
+	}
+	if c.RateLimit != nil {
+		if err := validateRateLimit(*c.RateLimit); err != nil {
+			problems = append(problems, "rate_limit: "+err.Error())
+		}
+	}
+	for _, secret := range c.JWTSecrets {
+		if secret == "" {
+			problems = append(problems, "jwt_secrets: 密钥不能为空")
+			break
+		}
+	}
+	return problems
+}
+
+// loadConfigFile NewServerX 调用：读取配置文件并装配对应的模块
+func (s *ServerX) loadConfigFile() []string {
+	config, err := readRuntimeConfig(s.configFile)
+	if err != nil {
+		return []string{fmt.Sprintf("读取配置文件失败: %v", err)}
+	}
+	if problems := config.validate(); len(problems) > 0 {
+		return problems
+	}
+
+	// 模块已经由代码里的选项启用时只更新参数，否则按文件启用
+	var problems []string
+	install := func(opt ServerOption) {
+		if err := opt(s); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if config.Address != "" {
+		s.address = config.Address
+	}
+	if config.LogLevel != "" {
+		if s.loggerModule != nil {
+			s.loggerModule.SetLevel(config.LogLevel)
+		} else {
+			install(WithLogging(config.LogLevel))
+		}
+	}
+	if config.RateLimit != nil {
+		if s.rateLimiter != nil {
+			s.rateLimiter.SetLimit(config.RateLimit.Rate, config.RateLimit.Burst)
+		} else {
+			install(WithRateLimit(config.RateLimit.Rate, config.RateLimit.Burst))
+		}
+	}
+	if len(config.CORSOrigins) > 0 {
+		if s.cors != nil {
+			s.cors.SetOrigins(config.CORSOrigins...)
+		} else {
+			install(WithCORS(config.CORSOrigins...))
+		}
+	}
+	if len(config.JWTSecrets) > 0 {
+		if s.jwtModule == nil {
+			install(WithJWTAuth(config.JWTSecrets[0]))
+		}
+		s.jwtModule.SetSecrets(config.JWTSecrets...)
+	}
+	return problems
+}
+
+// currentRuntimeConfig 当前实际生效的配置（从各个模块读取）
+func (s *ServerX) currentRuntimeConfig() RuntimeConfig {
+	config := RuntimeConfig{Address: s.address}
+	if s.loggerModule != nil {
+		config.LogLevel = s.loggerModule.Level()
+	}
+	if s.rateLimiter != nil {
+		rate, burst := s.rateLimiter.Limit()
+		config.RateLimit = &RateLimitConfig{Rate: rate, Burst: burst}
+	}
+	if s.cors != nil {
+		config.CORSOrigins = s.cors.Origins()
+	}
+	if s.jwtModule != nil {
+		config.JWTSecrets = s.jwtModule.Secrets()
+	}
+	return config
+}
+
+// configChange 一项配置的变化
+type configChange struct {
+	key      string
+	old, new string
+	rejected string // 不为空时表示不能热更新的原因
+}
+
+// diffRuntimeConfig 逐项对比；文件里没写的项（零值）视为不变
+func diffRuntimeConfig(current, file RuntimeConfig) []configChange {
+	var changes []configChange
+	cv, fv := reflect.ValueOf(current), reflect.ValueOf(file)
+	t := cv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		oldValue, newValue := cv.Field(i), fv.Field(i)
+		if newValue.IsZero() || reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			continue
+		}
+		change := configChange{
+			key: field.Tag.Get("yaml"),
+			old: formatConfigValue(field, oldValue),
+			new: formatConfigValue(field, newValue),
+		}
+		switch {
+		case field.Tag.Get("reload") == "restart":
+			change.rejected = "不支持热更新"
+		case oldValue.IsZero():
+			change.rejected = "对应的模块启动时没有启用"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// formatConfigValue 打印用，密钥打码
+func formatConfigValue(field reflect.StructField, v reflect.Value) string {
+	if v.IsZero() {
+		return "(未设置)"
+	}
+	if field.Tag.Get("secret") == "true" {
+		var masked []string
+		for _, secret := range v.Interface().([]string) {
+			masked = append(masked, redact.Mask(secret))
+		}
+		return fmt.Sprint(masked)
+	}
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Reload 重新读取配置文件并热更新；校验失败时什么都不改
+// 有不可热更新的变化时，其余的变化照常生效，并返回错误说明哪些需要重启
+func (s *ServerX) Reload() error {
+	if s.configFile == "" {
+		return errors.New("没有配置文件（WithConfigFile）")
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	file, err := readRuntimeConfig(s.configFile)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败，继续使用当前配置: %v", err)
+	}
+	if problems := file.validate(); len(problems) > 0 {
+		return &validate.ConfigError{Subject: configErrorSubject, Problems: problems}
+	}
+
+	changes := diffRuntimeConfig(s.currentRuntimeConfig(), *file)
+	if len(changes) == 0 {
+		log.Printf("🔄 配置文件没有变化: %s", s.configFile)
+		return nil
+	}
+
+	var rejected []string
+	for _, c := range changes {
+		if c.rejected != "" {
+			log.Printf("   ⛔ %s: %s -> %s（%s，需要重启）", c.key, c.old, c.new, c.rejected)
+			rejected = append(rejected, c.key)
+			continue
+		}
+		log.Printf("   ✅ %s: %s -> %s", c.key, c.old, c.new)
+		switch c.key {
+		case "log_level":
+			s.loggerModule.SetLevel(file.LogLevel)
+		case "rate_limit":
+			s.rateLimiter.SetLimit(file.RateLimit.Rate, file.RateLimit.Burst)
+		case "cors_origins":
+			s.cors.SetOrigins(file.CORSOrigins...)
+		case "jwt_secrets":
+			s.jwtModule.SetSecrets(file.JWTSecrets...)
+		}
+	}
+	log.Printf("🔄 配置已热更新: %d 项生效，%d 项被拒绝", len(changes)-len(rejected), len(rejected))
+
+	if len(rejected) > 0 {
+		return fmt.Errorf("以下配置需要重启才能生效: %s", strings.Join(rejected, ", "))
+	}
+	return nil
+}
+
+// WatchConfig 监听配置文件和 SIGHUP，变化时调用 Reload，直到 ctx 结束
+func (s *ServerX) WatchConfig(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// 监听所在目录而不是文件本身：编辑器保存、Kubernetes 更新 ConfigMap 都是“写新文件再替换”，
+	// 直接监听文件会在第一次替换后失效
+	dir, name := filepath.Split(s.configFile)
+	if dir == "" {
+		dir = "."
+	}
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	reload := func(reason string) {
+		log.Printf("🔄 %s，重新加载配置", reason)
+		if err := s.Reload(); err != nil {
+			log.Printf("⚠️  配置热更新: %v", err)
+		}
+	}
+
+	// 一次保存往往触发好几个事件，等安静 200ms 后再加载
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			base := filepath.Base(event.Name)
+			if base == name || base == "..data" { // ..data 是 Kubernetes 挂载卷切换版本用的符号链接
+				debounce = time.After(200 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("⚠️  监听配置文件出错: %v", err)
+		case <-debounce:
+			debounce = nil
+			reload("配置文件已修改")
+		case <-hup:
+			reload("收到 SIGHUP")
+		}
+	}
+}
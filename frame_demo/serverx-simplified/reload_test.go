@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"frame_demo/validate"
+)
+
+func TestDiffRuntimeConfig(t *testing.T) {
+	current := RuntimeConfig{
+		Address:    "0.0.0.0:8080",
+		LogLevel:   "info",
+		RateLimit:  &RateLimitConfig{Rate: 100, Burst: 200},
+		JWTSecrets: []string{"secret-v1"},
+	}
+
+	tests := []struct {
+		name         string
+		file         RuntimeConfig
+		wantApplied  []string
+		wantRejected []string
+	}{
+		{
+			name: "文件里没写的项视为不变",
+			file: RuntimeConfig{},
+		},
+		{
+			name: "值相同不算变化",
+			file: RuntimeConfig{Address: "0.0.0.0:8080", LogLevel: "info", RateLimit: &RateLimitConfig{Rate: 100, Burst: 200}},
+		},
+		{
+			name:        "可热更新的项",
+			file:        RuntimeConfig{LogLevel: "debug", RateLimit: &RateLimitConfig{Rate: 500, Burst: 200}, JWTSecrets: []string{"secret-v2", "secret-v1"}},
+			wantApplied: []string{"log_level", "rate_limit", "jwt_secrets"},
+		},
+		{
+			name:         "监听地址不能热更新",
+			file:         RuntimeConfig{Address: "0.0.0.0:9090", LogLevel: "warn"},
+			wantApplied:  []string{"log_level"},
+			wantRejected: []string{"address"},
+		},
+		{
+			name:         "启动时没有启用的模块不能热更新",
+			file:         RuntimeConfig{CORSOrigins: []string{"https://app.example.com"}},
+			wantRejected: []string{"cors_origins"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied, rejected []string
+			for _, c := range diffRuntimeConfig(current, tt.file) {
+				if c.rejected != "" {
+					rejected = append(rejected, c.key)
+				} else {
+					applied = append(applied, c.key)
+				}
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("生效 = %v，期望 %v", applied, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("拒绝 = %v，期望 %v", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestDiffRuntimeConfig_MasksSecrets(t *testing.T) {
+	changes := diffRuntimeConfig(
+		RuntimeConfig{JWTSecrets: []string{"secret-v1"}},
+		RuntimeConfig{JWTSecrets: []string{"secret-v2", "secret-v1"}},
+	)
+	if len(changes) != 1 {
+		t.Fatalf("changes = %+v，期望 1 项", changes)
+	}
+	if c := changes[0]; strings.Contains(c.old+c.new, "secret-v") {
+		t.Errorf("差异里出现了明文密钥: %s -> %s", c.old, c.new)
+	}
+}
+
+// newReloadServer 用 content 作为配置文件创建 ServerX，返回 server 和改写配置文件的函数
+func newReloadServer(t *testing.T, content string) (*ServerX, func(string)) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "serverx.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(content)
+	server, err := NewServerX(WithConfigFile(path))
+	if err != nil {
+		t.Fatalf("NewServerX: %v", err)
+	}
+	return server, write
+}
+
+func TestReload(t *testing.T) {
+	server, write := newReloadServer(t, `address: 0.0.0.0:8080
+log_level: info
+rate_limit: {rate: 100, burst: 200}
+cors_origins: [https://app.example.com]
+jwt_secrets: [secret-v1]
+`)
+
+	// 文件没变
+	if err := server.Reload(); err != nil {
+		t.Fatalf("文件没变时 Reload = %v", err)
+	}
+
+	// 可热更新的项生效，改端口被拒绝并返回错误
+	write(`address: 0.0.0.0:9090
+log_level: debug
+rate_limit: {rate: 500, burst: 1000}
+cors_origins: [https://app.example.com, https://admin.example.com]
+jwt_secrets: [secret-v2, secret-v1]
+`)
+	err := server.Reload()
+	if err == nil || !strings.Contains(err.Error(), "address") {
+		t.Fatalf("Reload = %v，期望说明 address 需要重启", err)
+	}
+	want := RuntimeConfig{
+		Address:     "0.0.0.0:8080",
+		LogLevel:    "debug",
+		RateLimit:   &RateLimitConfig{Rate: 500, Burst: 1000},
+		CORSOrigins: []string{"https://admin.example.com", "https://app.example.com"},
+		JWTSecrets:  []string{"secret-v2", "secret-v1"},
+	}
+	if got := server.currentRuntimeConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("热更新后 = %+v，期望 %+v", got, want)
+	}
+
+	// 来源只是顺序不同，不算变化
+	write("cors_origins: [https://app.example.com, https://admin.example.com]\n")
+	file, err := readRuntimeConfig(server.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := diffRuntimeConfig(server.currentRuntimeConfig(), *file); len(changes) != 0 {
+		t.Errorf("来源顺序不同被当成了变化: %+v", changes)
+	}
+
+	// 只写部分项：其它项保持不变
+	write("log_level: warn\n")
+	if err := server.Reload(); err != nil {
+		t.Fatalf("Reload = %v", err)
+	}
+	want.LogLevel = "warn"
+	if got := server.currentRuntimeConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("部分更新后 = %+v，期望 %+v", got, want)
+	}
+
+	// 校验失败整体拒绝，什么都不改
+	write("log_level: verbose\nrate_limit: {rate: 0, burst: 0}\n")
+	err = server.Reload()
+	var configErr *validate.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Fatalf("Reload = %v，期望包含 2 个问题的 ConfigError", err)
+	}
+	if got := server.currentRuntimeConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("校验失败后配置被修改了: %+v", got)
+	}
+
+	// 文件格式错误同样保持当前配置
+	write("log_level: [debug\n")
+	if err := server.Reload(); err == nil {
+		t.Error("YAML 格式错误时 Reload 应该失败")
+	}
+	if got := server.currentRuntimeConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("读取失败后配置被修改了: %+v", got)
+	}
+}
+
+func TestReload_ModuleNotEnabled(t *testing.T) {
+	server, write := newReloadServer(t, "log_level: info\n")
+
+	write("log_level: error\ncors_origins: [https://app.example.com]\n")
+	err := server.Reload()
+	if err == nil || !strings.Contains(err.Error(), "cors_origins") {
+		t.Fatalf("Reload = %v，期望说明 cors_origins 需要重启", err)
+	}
+	if server.cors != nil {
+		t.Error("热更新不应该启用新模块")
+	}
+	if level := server.loggerModule.Level(); level != "error" {
+		t.Errorf("日志级别 = %q，被拒绝的项不应影响其它项生效", level)
+	}
+}
+
+func TestReload_WithoutConfigFile(t *testing.T) {
+	server, err := NewServerX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(); err == nil {
+		t.Error("没有 WithConfigFile 时 Reload 应该失败")
+	}
+}
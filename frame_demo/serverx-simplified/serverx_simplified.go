@@ -2,18 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"frame_demo/middleware"
+	"frame_demo/validate"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	// 引入生成的 pb 代码，greeter.proto 的描述符会在 init 时注册到全局注册表
+	_ "grpc-learning/proto"
 )
 
 // ==================== 模拟完整版 serverx ====================
@@ -32,61 +44,143 @@ type ServerX struct {
 	grpcRegisters []func(*grpc.Server)
 	httpRegisters []func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error
 
+	// 动态转码（按描述符生成 REST 接口，无需 Gateway 生成代码）
+	dynamicGateway  bool
+	httpRuleConfigs []string
+
+	// 流式网关（服务端流 -> SSE，双向流 -> WebSocket）
+	streamingGateway bool
+	streamHeartbeat  time.Duration
+
 	// 拦截器
 	unaryInterceptors  []grpc.UnaryServerInterceptor
 	streamInterceptors []grpc.StreamServerInterceptor
+	unaryNames         map[int]string     // 拦截器下标 -> 追踪时的显示名
+	chainTracer        *middleware.Tracer // 不为空时记录每个请求经过拦截器链的时间线
+
+	// Gateway 调用 gRPC 时使用的客户端拦截器（超时、重试等）
+	gatewayClientInterceptors []grpc.UnaryClientInterceptor
+
+	// HTTP 中间件（只作用于 Gateway 分支）
+	httpMiddlewares []scopedHTTPMiddleware
 
 	// 模块
 	jwtModule    *JWTModule
 	loggerModule *LoggerModule
+	modules      []Module
+
+	// 可热更新的组件和配置文件（见 reload.go）
+	rateLimiter *middleware.RateLimiter
+	cors        *CORSPolicy
+	configFile  string
+	reloadMu    sync.Mutex
 }
 
-// 选项类型
-type ServerOption func(*ServerX)
+// 选项类型：参数不合法时返回错误，NewServerX 会把所有错误汇总后一起返回
+type ServerOption func(*ServerX) error
 
 // 第二步：实现各种选项函数（这就是 serverx 的核心API）
 
+// WithAddress - 设置监听地址，默认 0.0.0.0:8080
+func WithAddress(address string) ServerOption {
+	return func(s *ServerX) error {
+		s.address = address
+		return nil
+	}
+}
+
 // WithGrpcRegisters - 注册 gRPC 服务
 func WithGrpcRegisters(registers ...func(*grpc.Server)) ServerOption {
-	return func(s *ServerX) {
+	return func(s *ServerX) error {
 		s.grpcRegisters = append(s.grpcRegisters, registers...)
+		return nil
 	}
 }
 
 // WithHttpRegisters - 注册 HTTP 服务
 func WithHttpRegisters(registers ...func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error) ServerOption {
-	return func(s *ServerX) {
+	return func(s *ServerX) error {
 		s.httpRegisters = append(s.httpRegisters, registers...)
+		return nil
 	}
 }
 
 // WithUnaryInterceptors - 添加一元拦截器
 func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
-	return func(s *ServerX) {
+	return func(s *ServerX) error {
 		s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithSharedInterceptors - 添加共享中间件包里的拦截器（和 mini-framework 用的是同一份代码）
+func WithSharedInterceptors(interceptors ...middleware.Interceptor) ServerOption {
+	return func(s *ServerX) error {
+		// 转换后的函数名都是 ToUnaryServerInterceptor，记下原来的名字给链路追踪用
+		if s.unaryNames == nil {
+			s.unaryNames = map[int]string{}
+		}
+		for _, interceptor := range interceptors {
+			s.unaryNames[len(s.unaryInterceptors)] = middleware.FuncName(interceptor)
+			s.unaryInterceptors = append(s.unaryInterceptors, middleware.ToUnaryServerInterceptor(interceptor))
+		}
+		return nil
+	}
+}
+
+// WithChainTracing - 记录最近 limit 个请求在拦截器链中的执行时间线
+// 通过 GET /debug/chain 查看（?format=text 为可读文本，?method= 按方法过滤）
+// 会暴露请求细节，只应在调试环境开启
+func WithChainTracing(limit int) ServerOption {
+	return func(s *ServerX) error {
+		s.chainTracer = middleware.NewTracer(limit)
+		return nil
+	}
+}
+
+// WithGatewayClientInterceptors - Gateway 把 HTTP 请求转发给 gRPC 时使用的客户端拦截器
+// 例如 middleware.Timeout 给没有带超时的 HTTP 请求加上默认超时
+func WithGatewayClientInterceptors(interceptors ...middleware.ClientInterceptor) ServerOption {
+	return func(s *ServerX) error {
+		s.gatewayClientInterceptors = append(s.gatewayClientInterceptors, middleware.ToUnaryClientInterceptors(interceptors...)...)
+		return nil
+	}
+}
+
+// WithStreamInterceptors - 添加流式拦截器
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(s *ServerX) error {
+		s.streamInterceptors = append(s.streamInterceptors, interceptors...)
+		return nil
 	}
 }
 
 // WithJWTAuth - 启用 JWT 认证（关键理解点！）
 func WithJWTAuth(secret string) ServerOption {
-	return func(s *ServerX) {
-		s.jwtModule = &JWTModule{
-			enabled: true,
-			secret:  secret,
+	return func(s *ServerX) error {
+		if secret == "" {
+			return errors.New("WithJWTAuth: JWT 密钥不能为空")
 		}
-		// 自动将JWT拦截器添加到拦截器链
+		s.jwtModule = &JWTModule{enabled: true}
+		s.jwtModule.SetSecrets(secret)
+		// 自动将JWT拦截器添加到拦截器链（一元和流式都要认证）
 		s.unaryInterceptors = append(s.unaryInterceptors, s.jwtModule.Interceptor())
+		s.streamInterceptors = append(s.streamInterceptors, s.jwtModule.StreamInterceptor())
+		return nil
 	}
 }
 
 // WithLogging - 启用日志
 func WithLogging(level string) ServerOption {
-	return func(s *ServerX) {
-		s.loggerModule = &LoggerModule{
-			enabled: true,
-			level:   level,
+	return func(s *ServerX) error {
+		if err := validate.LogLevel(level); err != nil {
+			return fmt.Errorf("WithLogging: %v", err)
 		}
+		s.loggerModule = &LoggerModule{enabled: true}
+		s.loggerModule.SetLevel(level)
 		s.unaryInterceptors = append(s.unaryInterceptors, s.loggerModule.Interceptor())
+		s.streamInterceptors = append(s.streamInterceptors, s.loggerModule.StreamInterceptor())
+		return nil
 	}
 }
 
@@ -95,37 +189,78 @@ func WithLogging(level string) ServerOption {
 // JWT 模块
 type JWTModule struct {
 	enabled bool
-	secret  string
+	secrets atomic.Pointer[[]string] // 配置热更新时整体替换
+}
+
+// SetSecrets 替换有效的密钥，任意一个匹配即通过；轮换时新旧密钥可以同时有效一段时间
+func (j *JWTModule) SetSecrets(secrets ...string) {
+	secrets = append([]string(nil), secrets...)
+	j.secrets.Store(&secrets)
 }
 
+func (j *JWTModule) Secrets() []string {
+	return *j.secrets.Load()
+}
+
+func (j *JWTModule) Name() string { return "jwt" }
+
 func (j *JWTModule) Interceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		if !j.enabled {
 			return handler(ctx, req)
 		}
 
-		fmt.Printf("🔐 [JWT] 验证请求: %s\n", info.FullMethod)
+		if err := j.authenticate(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
 
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, fmt.Errorf("缺少认证信息")
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor 流式请求在建立流时认证一次
+func (j *JWTModule) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !j.enabled {
+			return handler(srv, ss)
 		}
 
-		token := md.Get("authorization")[0]
-		if token != "Bearer "+j.secret {
-			return nil, fmt.Errorf("无效的Token")
+		if err := j.authenticate(ss.Context(), info.FullMethod); err != nil {
+			return err
 		}
 
-		return handler(ctx, req)
+		return handler(srv, ss)
 	}
 }
 
+func (j *JWTModule) authenticate(ctx context.Context, fullMethod string) error {
+	fmt.Printf("🔐 [JWT] 验证请求: %s\n", fullMethod)
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("authorization")) == 0 {
+		return status.Errorf(codes.Unauthenticated, "缺少认证信息")
+	}
+
+	token := md.Get("authorization")[0]
+	for _, secret := range j.Secrets() {
+		if token == "Bearer "+secret {
+			return nil
+		}
+	}
+	return status.Errorf(codes.Unauthenticated, "无效的Token")
+}
+
 // 日志模块
 type LoggerModule struct {
 	enabled bool
-	level   string
+	level   atomic.Pointer[string] // 配置热更新时替换
 }
 
+func (l *LoggerModule) SetLevel(level string) { l.level.Store(&level) }
+func (l *LoggerModule) Level() string         { return *l.level.Load() }
+
+func (l *LoggerModule) Name() string { return "logger" }
+
 func (l *LoggerModule) Interceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		if !l.enabled {
@@ -133,7 +268,7 @@ func (l *LoggerModule) Interceptor() grpc.UnaryServerInterceptor {
 		}
 
 		start := time.Now()
-		fmt.Printf("📝 [Logger] 开始执行: %s (级别: %s)\n", info.FullMethod, l.level)
+		fmt.Printf("📝 [Logger] 开始执行: %s (级别: %s)\n", info.FullMethod, l.Level())
 
 		resp, err = handler(ctx, req)
 
@@ -144,8 +279,24 @@ func (l *LoggerModule) Interceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
+func (l *LoggerModule) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !l.enabled {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		fmt.Printf("📝 [Logger] 流开始: %s (级别: %s)\n", info.FullMethod, l.Level())
+
+		err := handler(srv, ss)
+
+		fmt.Printf("📝 [Logger] 流结束: %s, 持续: %v, 错误: %v\n", info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
 // 第四步：实现构造函数
-func NewServerX(options ...ServerOption) *ServerX {
+func NewServerX(options ...ServerOption) (*ServerX, error) {
 	// 创建默认服务器
 	server := &ServerX{
 		address:            "0.0.0.0:8080",
@@ -153,20 +304,41 @@ func NewServerX(options ...ServerOption) *ServerX {
 		streamInterceptors: []grpc.StreamServerInterceptor{},
 	}
 
-	// 应用所有选项
+	// 应用所有选项：出错也继续，把所有问题一次性收集起来
+	var problems []string
 	for _, opt := range options {
-		opt(server)
+		if err := opt(server); err != nil {
+			problems = append(problems, err.Error())
+		}
 	}
 
-	return server
+	// 配置文件里的值优先于代码里的选项
+	if server.configFile != "" {
+		problems = append(problems, server.loadConfigFile()...)
+	}
+
+	// 校验跨选项的规则（描述符、HTTP 规则等），启动前就发现问题
+	problems = append(problems, server.validate()...)
+	if len(problems) > 0 {
+		return nil, &validate.ConfigError{Subject: configErrorSubject, Problems: problems}
+	}
+	return server, nil
 }
 
 // 第五步：实现核心运行逻辑（这是最复杂的部分）
+// 配置在 NewServerX 中已经校验过（包括外部 HTTP 规则）
 func (s *ServerX) Run() error {
 	// 1. 创建 gRPC 服务器（带拦截器）
 	var grpcOpts []grpc.ServerOption
-	if len(s.unaryInterceptors) > 0 {
-		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(s.unaryInterceptors...))
+	unaryInterceptors := s.unaryInterceptors
+	if s.chainTracer != nil {
+		unaryInterceptors = s.chainTracer.InstrumentUnary(unaryInterceptors, s.unaryNames)
+	}
+	if len(unaryInterceptors) > 0 {
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(unaryInterceptors...))
+	}
+	if len(s.streamInterceptors) > 0 {
+		grpcOpts = append(grpcOpts, grpc.ChainStreamInterceptor(s.streamInterceptors...))
 	}
 
 	grpcServer := grpc.NewServer(grpcOpts...)
@@ -179,6 +351,9 @@ func (s *ServerX) Run() error {
 	// 3. 创建 HTTP Gateway
 	gwmux := runtime.NewServeMux()
 	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if len(s.gatewayClientInterceptors) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(s.gatewayClientInterceptors...))
+	}
 
 	// 4. 注册 HTTP 服务
 	for _, register := range s.httpRegisters {
@@ -187,10 +362,40 @@ func (s *ServerX) Run() error {
 		}
 	}
 
-	// 5. 创建双协议处理器（关键！）
-	handler := s.createDualProtocolHandler(grpcServer, gwmux)
+	// 5. 动态转码：没有生成 Gateway 代码的服务也能直接通过 HTTP 访问
+	var gatewayHandler http.Handler = gwmux
+	if s.dynamicGateway || s.streamingGateway {
+		conn, err := grpc.NewClient(s.address, dialOpts...)
+		if err != nil {
+			return fmt.Errorf("创建动态网关连接失败: %v", err)
+		}
+		defer conn.Close()
+		if s.dynamicGateway {
+			if err := s.registerDynamicRoutes(gwmux, conn); err != nil {
+				return fmt.Errorf("注册动态路由失败: %v", err)
+			}
+		}
+		if s.streamingGateway {
+			if gatewayHandler, err = s.registerStreamingRoutes(gwmux, conn); err != nil {
+				return fmt.Errorf("注册流式路由失败: %v", err)
+			}
+		}
+	}
+
+	// 6. 组装 HTTP 路由：Gateway + API 文档
+	httpMux := http.NewServeMux()
+	httpMux.Handle("/", gatewayHandler)
+	if err := s.mountAPIDocs(httpMux); err != nil {
+		return fmt.Errorf("生成API文档失败: %v", err)
+	}
+	if s.chainTracer != nil {
+		httpMux.Handle("GET /debug/chain", s.chainTracer)
+	}
+
+	// 7. 创建双协议处理器（关键！）
+	handler := s.createDualProtocolHandler(grpcServer, httpMux)
 
-	// 6. 启动服务器
+	// 8. 启动服务器
 	lis, err := net.Listen("tcp", s.address)
 	if err != nil {
 		return fmt.Errorf("监听端口失败: %v", err)
@@ -199,12 +404,29 @@ func (s *ServerX) Run() error {
 	log.Printf("🚀 ServerX 启动成功！双协议监听: %s", s.address)
 	log.Printf("   ✅ gRPC 服务: grpc://%s", s.address)
 	log.Printf("   ✅ HTTP 服务: http://%s", s.address)
+	if len(s.protobufFiles) > 0 {
+		log.Printf("   ✅ API 文档: http://%s/openapi.json (调试页面: /docs)", s.address)
+	}
+	if s.chainTracer != nil {
+		log.Printf("   🔍 拦截器链追踪: http://%s/debug/chain?format=text", s.address)
+	}
+	if s.configFile != "" {
+		go func() {
+			if err := s.WatchConfig(context.Background()); err != nil {
+				log.Printf("⚠️  无法监听配置文件，热更新不可用: %v", err)
+			}
+		}()
+		log.Printf("   🔄 配置热更新: 监听 %s（也可以 kill -HUP %d）", s.configFile, os.Getpid())
+	}
 
 	return http.Serve(lis, handler)
 }
 
 // 双协议处理器 - 这是 serverx 的核心魔法
-func (s *ServerX) createDualProtocolHandler(grpcServer *grpc.Server, gwMux *runtime.ServeMux) http.Handler {
+func (s *ServerX) createDualProtocolHandler(grpcServer *grpc.Server, httpMux http.Handler) http.Handler {
+	// HTTP 中间件只包裹 Gateway，gRPC 请求仍然只走拦截器链
+	httpHandler := s.buildHTTPHandler(httpMux)
+
 	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 判断请求类型
 		if r.ProtoMajor == 2 && strings.Contains(r.Header.Get("Content-Type"), "application/grpc") {
@@ -214,7 +436,7 @@ func (s *ServerX) createDualProtocolHandler(grpcServer *grpc.Server, gwMux *runt
 		} else {
 			// HTTP 请求
 			fmt.Printf("🌐 路由到 HTTP Gateway: %s %s\n", r.Method, r.URL.Path)
-			gwMux.ServeHTTP(w, r)
+			httpHandler.ServeHTTP(w, r)
 		}
 	}), &http2.Server{})
 }
@@ -263,7 +485,7 @@ func serverXImplementation() {
 	fmt.Println("\n=== ServerX 实现方式（简洁）===")
 
 	// 使用我们的 ServerX
-	NewServerX(
+	server, err := NewServerX(
 		WithGrpcRegisters(func(gs *grpc.Server) {
 			// 注册服务（框架会自动处理）
 			fmt.Println("✅ 注册 Greeter 服务")
@@ -275,20 +497,143 @@ func serverXImplementation() {
 		}),
 		WithJWTAuth("my-secret-key"),
 		WithLogging("debug"),
+		WithSharedInterceptors(middleware.Recovery()), // 和 MiniServer 共用
+		WithRateLimit(100, 200),                       // 限流和 CORS 都可以通过配置文件热更新
+		WithCORS("*"),
+		WithHTTPMiddlewaresFor("/v1/upload", BodyLimitMiddleware(1<<20)),
+		WithProtobufFiles("greeter.proto"), // 自动生成 /openapi.json 和 /docs
+		WithChainTracing(100),              // GET /debug/chain 查看请求卡在了哪个拦截器
 	)
+	if err != nil {
+		log.Printf("❌ 创建 ServerX 失败: %v", err)
+		return
+	}
 
 	fmt.Printf("✅ 只需要 10 行代码就完成了完整的服务器配置！\n")
-	fmt.Printf("✅ 包含了：双协议 + JWT认证 + 日志 + 拦截器链\n")
+	fmt.Printf("✅ 包含了：双协议 + JWT认证 + 日志 + 拦截器链 + 共享中间件 + HTTP中间件 + API文档\n")
+
+	// 从 greeter.proto 的 google.api.http 注解自动生成的 OpenAPI 文档
+	spec, err := server.OpenAPISpec()
+	if err != nil {
+		log.Printf("生成API文档失败: %v", err)
+	} else {
+		fmt.Printf("📄 /openapi.json 内容:\n%s\n", spec)
+	}
 
 	// server.Run() // 实际启动（这里演示，不真正运行）
 }
 
+// 方式3：动态转码（连 Gateway 代码都不用生成）
+func dynamicGatewayImplementation() {
+	fmt.Println("\n=== 动态转码方式（无需 grpc-gateway 生成代码）===")
+
+	_, err := NewServerX(
+		WithGrpcRegisters(func(gs *grpc.Server) {
+			// 只需要注册 gRPC 实现，不再需要 RegisterGreeterHandlerFromEndpoint
+			fmt.Println("✅ 注册 Greeter 服务（仅 gRPC 实现）")
+		}),
+		WithProtobufFiles("greeter.proto"),
+		WithDynamicGateway(),
+		WithGatewayClientInterceptors(middleware.Timeout(middleware.TimeoutPolicy{Default: 3 * time.Second})), // HTTP 请求默认 3 秒超时
+		WithHTTPRuleConfig("http_rules.yaml"), // 改不了 proto 时，用外部规则补充/覆盖注解
+		WithStreamingGateway(15*time.Second),  // 流式方法：SSE + WebSocket
+	)
+	if err != nil {
+		log.Printf("❌ 创建 ServerX 失败: %v", err)
+		return
+	}
+
+	fmt.Println("✅ POST /v1/sayhello 由描述符中的 google.api.http 注解在运行时生成")
+	fmt.Println("✅ GET /v1/greeter/{name} 来自 http_rules.yaml，规则有误时启动即报错")
+	fmt.Println("✅ GET /v1/sayhello/stream (Accept: text/event-stream) 以 SSE 推送服务端流")
+	fmt.Println("✅ GET /v1/chat (WebSocket) 承载双向流，断开连接即取消 gRPC 流")
+	fmt.Println("✅ 其他团队的服务可以用 WithDescriptorSet(\"greeter.binpb\") 直接加载描述符集")
+}
+
+// 方式4：配置有误时构造直接失败，所有问题一次列出
+func validationImplementation() {
+	fmt.Println("\n=== 配置校验（NewServerX 汇总所有错误）===")
+
+	_, err := NewServerX(
+		WithAddress("localhost:99999"),
+		WithJWTAuth(""),
+		WithLogging("verbose"),
+		WithHTTPMiddlewaresFor("v1/upload", BodyLimitMiddleware(1<<20)),
+		WithDynamicGateway(), // 没有 WithProtobufFiles
+	)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
+}
+
+// 方式5：配置热更新
+func hotReloadImplementation() {
+	fmt.Println("\n=== 配置热更新（WithConfigFile）===")
+
+	dir, err := os.MkdirTemp("", "serverx-reload")
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "serverx.yaml")
+	writeConfig := func(content string) bool {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			log.Printf("❌ 写入配置文件失败: %v", err)
+			return false
+		}
+		return true
+	}
+
+	if !writeConfig(`address: 0.0.0.0:8080
+log_level: info
+rate_limit: {rate: 100, burst: 200}
+cors_origins: [https://app.example.com]
+jwt_secrets: [secret-v1]
+`) {
+		return
+	}
+
+	server, err := NewServerX(WithConfigFile(path))
+	if err != nil {
+		log.Printf("❌ 创建 ServerX 失败: %v", err)
+		return
+	}
+	fmt.Println("✅ 从配置文件启动：日志、限流、CORS、JWT 模块按文件自动启用")
+
+	// 运维修改了配置文件：调级别、放宽限流、加来源、轮换密钥（新旧并存），顺手改了端口
+	// Run 之后这一步由文件监听或 SIGHUP 自动触发，这里直接调用 Reload
+	if !writeConfig(`address: 0.0.0.0:9090
+log_level: debug
+rate_limit: {rate: 500, burst: 1000}
+cors_origins: [https://app.example.com, https://admin.example.com]
+jwt_secrets: [secret-v2, secret-v1]
+`) {
+		return
+	}
+	if err := server.Reload(); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+
+	// 写错的配置整体拒绝，继续使用当前配置
+	if !writeConfig("log_level: verbose\nrate_limit: {rate: 0, burst: 0}\n") {
+		return
+	}
+	if err := server.Reload(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
+	fmt.Printf("✅ 当前日志级别: %s，监听地址仍为: %s\n", server.loggerModule.Level(), server.address)
+}
+
 func main() {
 	fmt.Println("=== 🎯 ServerX 框架设计原理演示 ===")
 
 	// 展示两种方式的对比
 	originalImplementation()
 	serverXImplementation()
+	dynamicGatewayImplementation()
+	validationImplementation()
+	hotReloadImplementation()
 
 	fmt.Println("\n=== 💡 理解框架开发的核心思想 ===")
 	fmt.Println("1. 📦 封装复杂性：将复杂的基础设施代码封装起来")
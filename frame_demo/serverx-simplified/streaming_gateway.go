@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ==================== 流式方法：服务端流 -> SSE，双向流 -> WebSocket ====================
+// 生成的 Gateway 只会把服务端流转成换行分隔的 JSON，浏览器用起来并不方便：
+//   - 服务端流：浏览器原生支持 EventSource (SSE)，自动重连、按事件解析
+//   - 双向流：HTTP/1.1 请求体不能边发边收，只能用 WebSocket
+// 三个必须处理好的问题：
+//   1. 背压：gRPC 消息先进有界缓冲区，缓冲区满了就不再 Recv，让 gRPC 流控把压力传回服务端
+//   2. 心跳：空闲时定期发心跳，防止代理/负载均衡器把长连接当成死连接断掉
+//   3. 取消：浏览器断开时取消 context，服务端的 stream.Context() 会立刻感知到；
+//      WebSocket 正常关闭（1000/1001）不算断开，而是 CloseSend，服务端 Recv 得到 io.EOF 后还能把响应发完
+
+const (
+	defaultStreamHeartbeat = 15 * time.Second
+	streamBufferSize       = 16 // 每个流最多缓冲的消息数
+)
+
+// WithStreamingGateway - 把流式方法暴露给浏览器，heartbeat <= 0 时使用默认 15 秒
+// 需要配合 WithProtobufFiles / WithDescriptorSet 提供描述符
+func WithStreamingGateway(heartbeat time.Duration) ServerOption {
+	return func(s *ServerX) error {
+		if heartbeat <= 0 {
+			heartbeat = defaultStreamHeartbeat
+		}
+		s.streamingGateway = true
+		s.streamHeartbeat = heartbeat
+		return nil
+	}
+}
+
+// registerStreamingRoutes 流式路由单独放一个 mux：
+// 只有 SSE / WebSocket 请求会进来，匹配不上时回落到普通 Gateway
+func (s *ServerX) registerStreamingRoutes(gwMux *runtime.ServeMux, conn *grpc.ClientConn) (http.Handler, error) {
+	bindings, err := s.httpBindings()
+	if err != nil {
+		return nil, err
+	}
+
+	streamMux := runtime.NewServeMux(runtime.WithRoutingErrorHandler(
+		func(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, code int) {
+			gwMux.ServeHTTP(w, r)
+		}))
+
+	for _, binding := range bindings {
+		md := binding.method
+		base := dynamicTranscoder{binding: binding, mux: streamMux, conn: conn}
+		switch {
+		case md.IsStreamingServer() && !md.IsStreamingClient():
+			t := &sseTranscoder{dynamicTranscoder: base, heartbeat: s.streamHeartbeat}
+			if err := streamMux.HandlePath(binding.verb, binding.path, t.ServeHTTP); err != nil {
+				return nil, fmt.Errorf("注册 SSE 路由 %s 失败: %v", binding.path, err)
+			}
+			log.Printf("   📡 SSE 路由: %s %s -> %s", binding.verb, binding.path, fullMethodName(md))
+		case md.IsStreamingServer() && md.IsStreamingClient():
+			// WebSocket 握手永远是 GET，与注解里的 HTTP 方法无关
+			t := &websocketTranscoder{dynamicTranscoder: base, heartbeat: s.streamHeartbeat}
+			if err := streamMux.HandlePath(http.MethodGet, binding.path, t.ServeHTTP); err != nil {
+				return nil, fmt.Errorf("注册 WebSocket 路由 %s 失败: %v", binding.path, err)
+			}
+			log.Printf("   🔌 WebSocket 路由: GET %s -> %s", binding.path, fullMethodName(md))
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") || websocket.IsWebSocketUpgrade(r) {
+			streamMux.ServeHTTP(w, r)
+			return
+		}
+		gwMux.ServeHTTP(w, r)
+	}), nil
+}
+
+// promoteAccessToken EventSource 和 WebSocket 都不能自定义请求头，
+// 允许用 ?access_token= 传 Token，转成 Authorization 头后再转发给 gRPC
+func promoteAccessToken(r *http.Request) {
+	query := r.URL.Query()
+	token := query.Get("access_token")
+	if token == "" {
+		return
+	}
+	if r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	query.Del("access_token")
+	r.URL.RawQuery = query.Encode()
+}
+
+// openStream 建立到 gRPC 服务的流，并把 HTTP 头转成 metadata
+func (t *dynamicTranscoder) openStream(ctx context.Context, r *http.Request) (grpc.ClientStream, error) {
+	md := t.binding.method
+	method := fullMethodName(md)
+	ctx, err := runtime.AnnotateContext(ctx, t.mux, r, method, runtime.WithHTTPPathPattern(t.binding.path))
+	if err != nil {
+		return nil, err
+	}
+	desc := &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}
+	return t.conn.NewStream(ctx, desc, method)
+}
+
+// receiveInto 在后台 goroutine 中接收 gRPC 消息，写入有界 channel
+// channel 满了就阻塞在这里不再 RecvMsg —— 这就是背压
+func receiveInto(ctx context.Context, stream grpc.ClientStream, output protoreflect.MessageDescriptor) (<-chan proto.Message, <-chan error) {
+	msgs := make(chan proto.Message, streamBufferSize)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(msgs)
+		for {
+			m := dynamicpb.NewMessage(output)
+			if err := stream.RecvMsg(m); err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case msgs <- m:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return msgs, errc
+}
+
+// ==================== SSE：服务端流 ====================
+
+type sseTranscoder struct {
+	dynamicTranscoder
+	heartbeat time.Duration
+}
+
+func (t *sseTranscoder) ServeHTTP(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	inbound, outbound := runtime.MarshalerForRequest(t.mux, r)
+	md := t.binding.method
+	promoteAccessToken(r)
+
+	// 浏览器断开 -> r.Context() 取消 -> gRPC 流取消 -> 服务端 stream.Context().Done()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	req := dynamicpb.NewMessage(md.Input())
+	if err := t.decodeRequest(r, inbound, req, pathParams); err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+	stream, err := t.openStream(ctx, r)
+	if err == nil {
+		err = stream.SendMsg(req)
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+	msgs, errc := receiveInto(ctx, stream, md.Output())
+
+	// 写 SSE 头之前先等第一条消息（最多一个心跳周期）：
+	// 认证失败这类立即结束的错误，浏览器能拿到正常的 HTTP 状态码
+	var first proto.Message
+	ended := false
+	select {
+	case m, ok := <-msgs:
+		if !ok {
+			if err := <-errc; err != io.EOF {
+				runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+				return
+			}
+			ended = true
+		}
+		first = m
+	case <-time.After(t.heartbeat):
+	case <-ctx.Done():
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(t.heartbeat)
+	defer ticker.Stop()
+
+	id := 1
+	emit := func(m proto.Message) error {
+		data, err := outbound.Marshal(m)
+		if err != nil {
+			return err
+		}
+		err = writeSSEEvent(w, id, "message", data)
+		id++
+		return err
+	}
+	finish := func(err error) {
+		if err == io.EOF {
+			writeSSEEvent(w, 0, "end", []byte("{}"))
+		} else {
+			data, _ := outbound.Marshal(status.Convert(err).Proto())
+			writeSSEEvent(w, 0, "error", data)
+		}
+		rc.Flush()
+	}
+
+	if first != nil {
+		if err := emit(first); err != nil {
+			finish(err)
+			return
+		}
+	}
+	if ended {
+		finish(io.EOF)
+		return
+	}
+	rc.Flush()
+
+	for {
+		var writeErr error
+		select {
+		case m, ok := <-msgs:
+			if !ok {
+				finish(<-errc)
+				return
+			}
+			writeErr = emit(m)
+		case <-ticker.C:
+			// 以冒号开头的行是 SSE 注释，EventSource 会忽略，只用来保活
+			_, writeErr = io.WriteString(w, ": heartbeat\n\n")
+		case <-ctx.Done():
+			log.Printf("📡 [SSE] 浏览器断开，取消流: %s", fullMethodName(md))
+			return
+		}
+		if writeErr == nil {
+			writeErr = rc.Flush()
+		}
+		if writeErr != nil {
+			log.Printf("📡 [SSE] 写入失败，取消流: %v", writeErr)
+			return
+		}
+	}
+}
+
+// writeSSEEvent 按 SSE 格式输出一个事件；多行数据每行都要加 data: 前缀
+func writeSSEEvent(w io.Writer, id int, event string, data []byte) error {
+	var b strings.Builder
+	if id > 0 {
+		fmt.Fprintf(&b, "id: %d\n", id)
+	}
+	fmt.Fprintf(&b, "event: %s\n", event)
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ==================== WebSocket：双向流 ====================
+
+// 默认只允许同源页面连接，跨域需要在前面加反向代理或自定义 CheckOrigin
+var wsUpgrader = websocket.Upgrader{}
+
+type websocketTranscoder struct {
+	dynamicTranscoder
+	heartbeat time.Duration
+}
+
+func (t *websocketTranscoder) ServeHTTP(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	inbound, outbound := runtime.MarshalerForRequest(t.mux, r)
+	md := t.binding.method
+	promoteAccessToken(r)
+
+	// 注意：连接被劫持（Hijack）后 net/http 不再监测它，浏览器断开时 r.Context() 不会被取消，
+	// 只能靠读失败或心跳超时自己 cancel
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stream, err := t.openStream(ctx, r)
+	if err != nil {
+		runtime.HTTPError(ctx, t.mux, outbound, w, r, err)
+		return
+	}
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 失败时已经写好了 HTTP 错误响应
+		return
+	}
+	defer ws.Close()
+
+	// 心跳：定期发送 ping，超过两个周期收不到 pong 就认为浏览器已经断开
+	ws.SetReadDeadline(time.Now().Add(2 * t.heartbeat))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(2 * t.heartbeat))
+	})
+	// 默认的 CloseHandler 收到关闭帧会立刻回一个关闭帧，之后就不能再写消息了；
+	// 关闭帧留到服务端结束流时由 closeWebSocket 发送，带上真正的 gRPC 状态
+	ws.SetCloseHandler(func(int, string) error { return nil })
+
+	// 浏览器 -> gRPC：SendMsg 受 gRPC 流控约束，阻塞时就不再读 WebSocket，
+	// TCP 接收窗口被填满，压力自然传回浏览器
+	halfClosed := make(chan struct{})
+	go func() {
+		for {
+			_, data, err := ws.ReadMessage()
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				// 浏览器正常关闭：相当于 CloseSend，服务端 Recv 得到 io.EOF，剩下的响应照常转发
+				stream.CloseSend()
+				close(halfClosed)
+				return
+			}
+			if err != nil {
+				// 连接异常断开或心跳超时：取消整个流
+				cancel()
+				return
+			}
+			m := dynamicpb.NewMessage(md.Input())
+			if err := inbound.Unmarshal(data, m); err != nil {
+				closeWebSocket(ws, status.Errorf(codes.InvalidArgument, "消息格式错误: %v", err))
+				cancel()
+				return
+			}
+			if err := stream.SendMsg(m); err != nil {
+				// 服务端已经结束了流，真正的错误由接收方向处理
+				return
+			}
+		}
+	}()
+
+	// gRPC -> 浏览器：所有写操作都在这个 goroutine 里（gorilla/websocket 只允许一个写者）
+	msgs, errc := receiveInto(ctx, stream, md.Output())
+	ticker := time.NewTicker(t.heartbeat)
+	defer ticker.Stop()
+	var drainTimeout <-chan time.Time // 浏览器关闭后最多再等一个心跳周期，服务端还不结束就取消
+
+	for {
+		select {
+		case m, ok := <-msgs:
+			if !ok {
+				err := <-errc
+				if err == io.EOF {
+					err = nil
+				}
+				closeWebSocket(ws, err)
+				return
+			}
+			data, err := outbound.Marshal(m)
+			if err != nil {
+				closeWebSocket(ws, err)
+				return
+			}
+			// 写超时：浏览器迟迟不读，说明它跟不上了，与其无限堆积不如断开
+			ws.SetWriteDeadline(time.Now().Add(t.heartbeat))
+			if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("🔌 [WebSocket] 写入失败，取消流: %v", err)
+				return
+			}
+		case <-ticker.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(t.heartbeat)); err != nil {
+				log.Printf("🔌 [WebSocket] 心跳失败，取消流: %v", err)
+				return
+			}
+		case <-halfClosed:
+			halfClosed = nil
+			drainTimeout = time.After(t.heartbeat)
+		case <-drainTimeout:
+			log.Printf("🔌 [WebSocket] 浏览器已关闭，服务端迟迟不结束流，取消: %s", fullMethodName(md))
+			closeWebSocket(ws, status.Error(codes.DeadlineExceeded, "等待服务端结束流超时"))
+			return
+		case <-ctx.Done():
+			log.Printf("🔌 [WebSocket] 浏览器断开，取消流: %s", fullMethodName(md))
+			return
+		}
+	}
+}
+
+// closeWebSocket 把 gRPC 状态映射成 WebSocket 关闭码，原因里带上 gRPC 错误信息
+func closeWebSocket(ws *websocket.Conn, err error) {
+	code, reason := websocket.CloseNormalClosure, ""
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.InvalidArgument:
+			code = websocket.CloseInvalidFramePayloadData
+		case codes.Unauthenticated, codes.PermissionDenied:
+			code = websocket.ClosePolicyViolation
+		default:
+			code = websocket.CloseInternalServerErr
+		}
+		reason = fmt.Sprintf("%s: %s", st.Code(), st.Message())
+	}
+	// 控制帧的负载最多 125 字节（2 字节关闭码 + 原因），按 rune 截断避免切坏中文
+	for len(reason) > 123 {
+		r := []rune(reason)
+		reason = string(r[:len(r)-1])
+	}
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+		log.Printf("🔌 [WebSocket] 发送关闭帧失败: %v", err)
+	}
+}
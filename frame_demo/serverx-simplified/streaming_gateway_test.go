@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "grpc-learning/proto"
+)
+
+// chatServer 回声聊天；客户端 CloseSend 后再发一条汇总消息，用来验证正常关闭时响应不会丢
+type chatServer struct {
+	pb.UnimplementedGreeterServer
+	ended chan error // Chat 返回时的错误
+}
+
+func (s *chatServer) Chat(stream pb.Greeter_ChatServer) error {
+	err := func() error {
+		var n int
+		for {
+			in, err := stream.Recv()
+			if err == io.EOF {
+				return stream.Send(&pb.HelloReply{Message: fmt.Sprintf("再见，共 %d 条", n)})
+			}
+			if err != nil {
+				return err
+			}
+			n++
+			if err := stream.Send(&pb.HelloReply{Message: "回声: " + in.GetName()}); err != nil {
+				return err
+			}
+		}
+	}()
+	s.ended <- err
+	return err
+}
+
+// startStreamingGateway 启动 gRPC 服务和只包含流式路由的 Gateway，返回 WebSocket 地址
+func startStreamingGateway(t *testing.T, chat *chatServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterGreeterServer(grpcServer, chat)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s, err := NewServerX(WithProtobufFiles("greeter.proto"), WithStreamingGateway(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := s.registerStreamingRoutes(runtime.NewServeMux(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/v1/chat"
+}
+
+func TestWebSocketNormalCloseHalfClosesStream(t *testing.T) {
+	for _, code := range []int{websocket.CloseNormalClosure, websocket.CloseGoingAway} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			chat := &chatServer{ended: make(chan error, 1)}
+			ws, _, err := websocket.DefaultDialer.Dial(startStreamingGateway(t, chat), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"name":"你好"}`)); err != nil {
+				t.Fatal(err)
+			}
+			if _, msg, err := ws.ReadMessage(); err != nil || !strings.Contains(string(msg), "回声: 你好") {
+				t.Fatalf("ReadMessage() = %s, %v", msg, err)
+			}
+
+			// 浏览器关闭：服务端应该收到 io.EOF，而不是 context 取消
+			if err := ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, "")); err != nil {
+				t.Fatal(err)
+			}
+			_, msg, err := ws.ReadMessage()
+			if err != nil || !strings.Contains(string(msg), "再见，共 1 条") {
+				t.Fatalf("关闭后应该还能收到剩下的响应，得到 %s, %v", msg, err)
+			}
+			_, _, err = ws.ReadMessage()
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("期望 1000 关闭帧，得到 %v", err)
+			}
+
+			select {
+			case err := <-chat.ended:
+				if err != nil {
+					t.Errorf("Chat 返回 %v，期望正常结束", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Chat 没有结束")
+			}
+		})
+	}
+}
+
+func TestWebSocketAbruptDisconnectCancelsStream(t *testing.T) {
+	chat := &chatServer{ended: make(chan error, 1)}
+	ws, _, err := websocket.DefaultDialer.Dial(startStreamingGateway(t, chat), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"name":"a"}`)); err != nil {
+		t.Fatal(err)
+	}
+	ws.ReadMessage()
+	ws.UnderlyingConn().Close() // 不发关闭帧直接断开
+
+	select {
+	case err := <-chat.ended:
+		if err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("异常断开时 Chat 应该看到取消，得到 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Chat 没有结束")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"frame_demo/validate"
+)
+
+// ==================== 配置校验 ====================
+// 每个 ServerOption 只检查自己的参数，下面这些规则要等所有选项都应用完才能判断：
+//   - 动态转码、流式网关、外部 HTTP 规则都依赖 WithProtobufFiles 提供的描述符
+//   - 描述符和 HTTP 规则在构造时就加载一遍，文件缺失、规则写错或 operationId 重复不用等到 Run
+//   - 监听地址必须是 host:port，模块名不能重复
+// 地址、日志级别的规则和 ConfigError 与 options-pattern 共用，见 frame_demo/validate。
+
+// configErrorSubject ServerX 的 ConfigError 开头
+const configErrorSubject = "ServerX 配置"
+
+// validate 检查跨选项的规则，返回所有问题
+func (s *ServerX) validate() []string {
+	var problems []string
+
+	if err := validate.Address(s.address); err != nil {
+		problems = append(problems, fmt.Sprintf("监听地址 %q 不合法: %v", s.address, err))
+	}
+
+	if len(s.protobufFiles) == 0 {
+		if s.dynamicGateway {
+			problems = append(problems, "WithDynamicGateway 需要同时配置 WithProtobufFiles")
+		}
+		if s.streamingGateway {
+			problems = append(problems, "WithStreamingGateway 需要同时配置 WithProtobufFiles")
+		}
+		if len(s.httpRuleConfigs) > 0 {
+			problems = append(problems, "WithHTTPRuleConfig 需要同时配置 WithProtobufFiles")
+		}
+	} else if bindings, err := s.httpBindings(); err != nil {
+		problems = append(problems, fmt.Sprintf("加载HTTP规则失败: %v", err))
+	} else if _, err := operationIDs(bindings); err != nil {
+		problems = append(problems, fmt.Sprintf("生成 OpenAPI 文档失败: %v", err))
+	}
+
+	seen := map[string]bool{}
+	for _, m := range s.modules {
+		if seen[m.Name()] {
+			problems = append(problems, fmt.Sprintf("模块 %q 重复注册", m.Name()))
+		}
+		seen[m.Name()] = true
+	}
+
+	return problems
+}
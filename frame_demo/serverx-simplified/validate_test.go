@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"frame_demo/validate"
+)
+
+func TestNewServerXAggregatesProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []ServerOption
+		want    []string // 每个问题的开头，按出现顺序
+	}{
+		{
+			name:    "默认配置合法",
+			options: nil,
+		},
+		{
+			name: "选项错误和跨选项规则一起返回",
+			options: []ServerOption{
+				WithAddress("localhost:99999"),
+				WithJWTAuth(""),
+				WithLogging("verbose"),
+				WithHTTPMiddlewaresFor("v1/upload", BodyLimitMiddleware(1<<20)),
+				WithDynamicGateway(),
+			},
+			want: []string{
+				"WithJWTAuth: JWT 密钥不能为空",
+				`WithLogging: 未知的日志级别 "verbose"`,
+				`WithHTTPMiddlewaresFor: 路径前缀 "v1/upload" 必须以 / 开头`,
+				`监听地址 "localhost:99999" 不合法`,
+				"WithDynamicGateway 需要同时配置 WithProtobufFiles",
+			},
+		},
+		{
+			name:    "端口 0 和 options-pattern 一样不合法",
+			options: []ServerOption{WithAddress("0.0.0.0:0")},
+			want:    []string{`监听地址 "0.0.0.0:0" 不合法`},
+		},
+		{
+			name:    "依赖描述符的选项",
+			options: []ServerOption{WithStreamingGateway(0), WithHTTPRuleConfig("http_rules.yaml")},
+			want: []string{
+				"WithStreamingGateway 需要同时配置 WithProtobufFiles",
+				"WithHTTPRuleConfig 需要同时配置 WithProtobufFiles",
+			},
+		},
+		{
+			name:    "模块名重复",
+			options: []ServerOption{WithModules(namedModule("audit"), namedModule("audit"), nil)},
+			want: []string{
+				"WithModules: 模块不能为 nil",
+				`模块 "audit" 重复注册`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServerX(tt.options...)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("NewServerX: %v", err)
+				}
+				return
+			}
+			if server != nil {
+				t.Error("出错时不应该返回 ServerX")
+			}
+			var configErr *validate.ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("期望 *validate.ConfigError，得到 %T: %v", err, err)
+			}
+			if !strings.HasPrefix(err.Error(), "ServerX 配置有") {
+				t.Errorf("错误信息开头不对: %q", err.Error())
+			}
+			if len(configErr.Problems) != len(tt.want) {
+				t.Fatalf("得到 %d 个问题 %q，期望 %d 个", len(configErr.Problems), configErr.Problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(configErr.Problems[i], want) {
+					t.Errorf("问题 %d = %q，期望以 %q 开头", i, configErr.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+type namedModule string
+
+func (m namedModule) Name() string { return string(m) }
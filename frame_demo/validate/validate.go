@@ -0,0 +1,51 @@
+// Package validate 是 options-pattern 和 serverx-simplified 共用的配置校验工具。
+//
+// 两边的构造函数都是先应用所有选项、再检查跨选项的规则，最后把所有问题汇总成一个
+// ConfigError 返回，改一次配置就能全部修好。监听地址、日志级别这类两边都有的字段，
+// 规则只在这里写一份，免得两个副本慢慢变得不一致。
+package validate
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ConfigError 配置校验失败，Problems 中每一项是一个独立的问题
+type ConfigError struct {
+	Subject  string // 出现在错误信息开头，如 "ServerX 配置"；为空时是 "配置"
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	subject := e.Subject
+	if subject == "" {
+		subject = "配置"
+	}
+	return fmt.Sprintf("%s有 %d 处错误:\n  - %s", subject, len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// logLevels 支持的日志级别
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// LogLevel 检查日志级别
+func LogLevel(level string) error {
+	if !logLevels[level] {
+		return fmt.Errorf("未知的日志级别 %q（可选 debug/info/warn/error）", level)
+	}
+	return nil
+}
+
+// Address 检查 host:port 格式，端口必须在 1-65535
+// 不接受 0（随机端口）：ServerX 的 Gateway 要按配置的地址回连自己，Redis 也不可能监听在 0 端口
+func Address(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("端口 %q 不合法", port)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"0.0.0.0:8080", false},
+		{"localhost:1", false},
+		{"[::1]:65535", false},
+		{":9090", false},
+		{"redis-0:0", true}, // 不接受随机端口，两个包规则一致
+		{"localhost:65536", true},
+		{"localhost:-1", true},
+		{"localhost:http", true},
+		{"localhost", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if err := Address(tt.address); (err != nil) != tt.wantErr {
+			t.Errorf("Address(%q) = %v，期望出错: %v", tt.address, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	for _, level := range []string{"debug", "info", "warn", "error"} {
+		if err := LogLevel(level); err != nil {
+			t.Errorf("LogLevel(%q) = %v", level, err)
+		}
+	}
+	for _, level := range []string{"", "verbose", "INFO"} {
+		if err := LogLevel(level); err == nil {
+			t.Errorf("LogLevel(%q) 应该出错", level)
+		}
+	}
+}
+
+func TestConfigErrorListsEveryProblem(t *testing.T) {
+	tests := []struct {
+		err  *ConfigError
+		want string
+	}{
+		{
+			&ConfigError{Subject: "ServerX 配置", Problems: []string{"a", "b"}},
+			"ServerX 配置有 2 处错误:\n  - a\n  - b",
+		},
+		{
+			&ConfigError{Problems: []string{"a"}},
+			"配置有 1 处错误:\n  - a",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q，期望 %q", got, tt.want)
+		}
+		if strings.Count(tt.err.Error(), "\n  - ") != len(tt.err.Problems) {
+			t.Errorf("每个问题应该单独一行: %q", tt.err.Error())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==================== 客户端拦截器 ====================
+// 和服务端拦截器一样，客户端也可以在连接上统一处理超时、重试，业务调用处不用再手写。
+// 签名是 grpc.UnaryClientInterceptor，通过 grpc.WithChainUnaryInterceptor 按顺序生效。
+
+// timeoutInterceptor 调用方没有设置 deadline 时，统一加上默认超时
+// 放在重试拦截器前面，超时就是所有重试加起来的总时间
+func timeoutInterceptor(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// retryInterceptor 只对幂等方法重试 Unavailable（服务端暂时不可用），每次等待时间翻倍
+func retryInterceptor(maxAttempts int, backoff time.Duration, idempotent ...string) grpc.UnaryClientInterceptor {
+	retryable := map[string]bool{}
+	for _, method := range idempotent {
+		retryable[method] = true
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || !retryable[method] || status.Code(err) != codes.Unavailable || attempt >= maxAttempts {
+				return err
+			}
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+	}
+}
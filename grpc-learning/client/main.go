@@ -4,6 +4,7 @@ package main
 import (
 	"context"
 	"google.golang.org/grpc/metadata"
+	"io"
 	"log"
 	"time"
 
@@ -16,7 +17,15 @@ import (
 func main() {
 	// 1. 连接到服务器地址
 	// grpc.WithTransportCredentials(insecure.NewCredentials()) 表示使用不安全的连接，学习时使用，生产环境需要证书
-	conn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// grpc.WithChainUnaryInterceptor 让这个连接上的所有一元调用都经过超时、重试拦截器
+	conn, err := grpc.Dial("localhost:50051",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		// 超时在外：1 秒是所有重试加起来的总时间；SayHello 没有副作用，可以重试
+		grpc.WithChainUnaryInterceptor(
+			timeoutInterceptor(time.Second),
+			retryInterceptor(3, 50*time.Millisecond, pb.Greeter_SayHello_FullMethodName),
+		),
+	)
 	if err != nil {
 		log.Fatalf("连接失败: %v", err)
 	}
@@ -26,9 +35,8 @@ func main() {
 	// 2. 创建一个 Greeter 服务的客户端 "存根" (Stub)
 	c := pb.NewGreeterClient(conn)
 
-	// 3. 设置一个带超时的 context (好习惯)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	// 3. 超时由连接上的 Timeout 拦截器统一设置，这里不用再手写 context.WithTimeout
+	ctx := context.Background()
 
 	// --- 变化点 2: 创建并附加 Metadata ---
 	// 1. 创建一个 metadata.MD 对象，它本质上是 map[string][]string
@@ -49,4 +57,22 @@ func main() {
 
 	// 5. 打印服务器返回的结果
 	log.Printf("从服务器收到的响应: %s", r.GetMessage())
+
+	// 6. 调用服务端流方法：一次请求，循环 Recv 直到 io.EOF
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer streamCancel()
+	stream, err := c.SayHelloStream(metadata.NewOutgoingContext(streamCtx, md), &pb.HelloRequest{Name: "Gemini"})
+	if err != nil {
+		log.Fatalf("调用 SayHelloStream 失败: %v", err)
+	}
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("接收流式响应失败: %v", err)
+		}
+		log.Printf("从服务器收到的流式响应: %s", reply.GetMessage())
+	}
 }
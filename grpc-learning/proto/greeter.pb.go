@@ -123,9 +123,11 @@ const file_greeter_proto_rawDesc = "" +
 	"\x04name\x18\x01 \x01(\tR\x04name\"&\n" +
 	"\n" +
 	"HelloReply\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage2V\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage2\xf8\x01\n" +
 	"\aGreeter\x12K\n" +
-	"\bSayHello\x12\x13.proto.HelloRequest\x1a\x11.proto.HelloReply\"\x17\x82\xd3\xe4\x93\x02\x11:\x01*\"\f/v1/sayhelloB\tZ\a./protob\x06proto3"
+	"\bSayHello\x12\x13.proto.HelloRequest\x1a\x11.proto.HelloReply\"\x17\x82\xd3\xe4\x93\x02\x11:\x01*\"\f/v1/sayhello\x12W\n" +
+	"\x0eSayHelloStream\x12\x13.proto.HelloRequest\x1a\x11.proto.HelloReply\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/v1/sayhello/stream0\x01\x12G\n" +
+	"\x04Chat\x12\x13.proto.HelloRequest\x1a\x11.proto.HelloReply\"\x13\x82\xd3\xe4\x93\x02\r:\x01*\"\b/v1/chat(\x010\x01B\tZ\a./protob\x06proto3"
 
 var (
 	file_greeter_proto_rawDescOnce sync.Once
@@ -146,9 +148,13 @@ var file_greeter_proto_goTypes = []any{
 }
 var file_greeter_proto_depIdxs = []int32{
 	0, // 0: proto.Greeter.SayHello:input_type -> proto.HelloRequest
-	1, // 1: proto.Greeter.SayHello:output_type -> proto.HelloReply
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	0, // 1: proto.Greeter.SayHelloStream:input_type -> proto.HelloRequest
+	0, // 2: proto.Greeter.Chat:input_type -> proto.HelloRequest
+	1, // 3: proto.Greeter.SayHello:output_type -> proto.HelloReply
+	1, // 4: proto.Greeter.SayHelloStream:output_type -> proto.HelloReply
+	1, // 5: proto.Greeter.Chat:output_type -> proto.HelloReply
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
@@ -11,9 +12,11 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	// 引入我们刚刚生成的 Go 代码包
 	pb "grpc-learning/proto" // 注意：请替换成你自己的 Go Module 路径
@@ -32,6 +35,42 @@ func (s *server) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloRe
 	return &pb.HelloReply{Message: "你好, " + in.GetName()}, nil
 }
 
+// 3. 实现 SayHelloStream 方法（服务端流）
+func (s *server) SayHelloStream(in *pb.HelloRequest, stream pb.Greeter_SayHelloStreamServer) error {
+	log.Printf("收到了流式问候请求: %v", in.GetName())
+	for i := 1; i <= 5; i++ {
+		// 客户端断开时 stream.Context() 会被取消，及时停止推送
+		select {
+		case <-stream.Context().Done():
+			log.Printf("客户端已断开，停止推送: %v", stream.Context().Err())
+			return stream.Context().Err()
+		case <-time.After(time.Second):
+		}
+		if err := stream.Send(&pb.HelloReply{Message: fmt.Sprintf("你好, %s (第 %d 条)", in.GetName(), i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 4. 实现 Chat 方法（双向流）：收到一条就回一条
+func (s *server) Chat(stream pb.Greeter_ChatServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			// 客户端发送完毕
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Chat 收到: %v", in.GetName())
+		if err := stream.Send(&pb.HelloReply{Message: "你好, " + in.GetName()}); err != nil {
+			return err
+		}
+	}
+}
+
 //func main() {
 //	// 3. 监听一个 TCP 端口
 //	lis, err := net.Listen("tcp", ":50051")